@@ -0,0 +1,240 @@
+package om
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// operations of audit records
+const (
+	AuditInsert = "INSERT"
+	AuditUpdate = "UPDATE"
+	AuditDelete = "DELETE"
+)
+
+// AuditRecord is a row level change of a tracked model,
+// Old is nil for inserts and New is nil for deletes,
+// both are JSON objects of `{column}=>{value}`
+type AuditRecord struct {
+	Table string
+	PK    interface{}
+	Op    string
+	Old   json.RawMessage
+	New   json.RawMessage
+	Actor string
+	At    time.Time
+}
+
+// AuditSink writes audit records,
+// db is the db or the transaction of the change,
+// so a sink writing by it is committed or rolled back with the change
+type AuditSink interface {
+	Write(ctx context.Context, db *DB, r *AuditRecord) error
+}
+
+// WithAudit records changes of tracked models by `Tables.Insert/Update/Delete` to the sink,
+// every row changed is recorded, changes of no rows are not,
+// a change out of a transaction runs in one with its records,
+// so a failed write rolls back the change together
+//
+// Example:
+//
+//	db := om.NewDB(sqlxDB, om.WithAudit(om.NewTableSink("audit_log")))
+//	ctx := om.WithActor(r.Context(), user.Name)
+//	_, err := db.Tb("car").WithContext(ctx).Update(&car).Where("id = ?", car.ID).Done()
+func WithAudit(sink AuditSink) Option {
+	return func(m *DB) {
+		m.audit = sink
+	}
+}
+
+type actorKey struct{}
+
+// WithActor returns the context carrying the actor of changes for audit records
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorOf returns the actor carried by the context, empty if none
+func ActorOf(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// TableSink writes audit records to a table like:
+//
+//	CREATE TABLE audit_log(
+//	  id INTEGER AUTO_INCREMENT PRIMARY KEY,
+//	  table_name VARCHAR(64) NOT NULL,
+//	  pk VARCHAR(64) NOT NULL,
+//	  op VARCHAR(8) NOT NULL,
+//	  old_data TEXT NULL,
+//	  new_data TEXT NULL,
+//	  actor VARCHAR(64) NOT NULL,
+//	  created_at DATETIME NOT NULL
+//	);
+type TableSink struct {
+	Table string
+}
+
+// NewTableSink makes the sink writing to the table
+func NewTableSink(table string) *TableSink {
+	return &TableSink{Table: table}
+}
+
+func (s *TableSink) Write(ctx context.Context, db *DB, r *AuditRecord) error {
+	// NULL rather than an empty string for the missing side
+	data := func(raw json.RawMessage) interface{} {
+		if raw == nil {
+			return nil
+		}
+		return string(raw)
+	}
+	_, err := db.Tb(s.Table).WithContext(ctx).InsertMap(map[string]interface{}{
+		"table_name": r.Table,
+		"pk":         fmt.Sprint(r.PK),
+		"op":         r.Op,
+		"old_data":   data(r.Old),
+		"new_data":   data(r.New),
+		"actor":      r.Actor,
+		"created_at": r.At,
+	}).Done()
+	return err
+}
+
+// audit writes the record of the change on the table if the db audits,
+// old and new are the columns before and after the change, nil if none
+func (t *Tables) audit(op string, pk interface{}, old map[string]interface{}, new map[string]interface{}) error {
+	sink := t.db.audit
+	if sink == nil {
+		return nil
+	}
+	ctx := t.context()
+	r := &AuditRecord{
+		Table: t.name,
+		PK:    pk,
+		Op:    op,
+		Actor: ActorOf(ctx),
+		At:    time.Now().UTC(),
+	}
+	var err error
+	if old != nil {
+		if r.Old, err = json.Marshal(old); err != nil {
+			return err
+		}
+	}
+	if new != nil {
+		if r.New, err = json.Marshal(new); err != nil {
+			return err
+		}
+	}
+	return sink.Write(ctx, t.db, r)
+}
+
+// auditTx runs the audited change by fn in a transaction if the db isn't one,
+// so the change and its audit records are committed together
+func (t *Tables) auditTx(fn func(t *Tables) error) error {
+	if t.db.sqlxDB == nil {
+		return fn(t)
+	}
+	return t.db.InTx(t.ctx, nil, func(tx *Tx) error {
+		txTables := *t
+		txTables.db = tx.db
+		return fn(&txTables)
+	})
+}
+
+// auditChange runs the change of the rows of the where by fn and records every row changed,
+// cols are the columns updated with new values of colsMap, nil for deletes
+func (t *Tables) auditChange(op string, manager *Manager, m isModel, where isExpr,
+	cols []string, colsMap map[string]interface{}, fn func(t *Tables) (int64, error)) (int64, error) {
+	pk := manager.pk
+	if holder, ok := m.(idHolder); ok && pk == "" {
+		pk, _ = holder.Identity()
+	}
+	if pk == "" {
+		return 0, errors.New("can't audit the model without pk")
+	}
+	var cnt int64
+	err := t.auditTx(func(t *Tables) error {
+		rows, err := t.loadRows(manager, pk, where)
+		if err != nil {
+			return err
+		}
+		if cnt, err = fn(t); err != nil || cnt == 0 {
+			return err
+		}
+		for _, row := range rows {
+			old, new := row, map[string]interface{}(nil)
+			if op == AuditUpdate {
+				old, new = pickCols(row, cols), pickCols(colsMap, cols)
+			}
+			if err := t.audit(op, row[pk], old, new); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return cnt, err
+}
+
+// loadRows loads the columns of the model and the pk from the rows of the where
+func (t *Tables) loadRows(manager *Manager, pk string, where isExpr) ([]map[string]interface{}, error) {
+	cols := manager.cols
+	if manager.colInfoMap[pk] == nil {
+		cols = append([]string{pk}, cols...)
+	}
+	q, args, err := NewTables(t.db, t.name).Select(t.quoteCols(cols)...).Where(where).toSql()
+	if err != nil {
+		return nil, err
+	}
+	rs, err := t.db.dbx.Queryx(t.context(), q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	var rows []map[string]interface{}
+	for rs.Next() {
+		row := map[string]interface{}{}
+		if err := rs.MapScan(row); err != nil {
+			return nil, err
+		}
+		for col, v := range row {
+			// text is scanned as bytes
+			if bs, ok := v.([]byte); ok {
+				row[col] = string(bs)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, rs.Err()
+}
+
+// pickCols returns the columns of the map in cols, all of them if cols is nil
+func pickCols(colsMap map[string]interface{}, cols []string) map[string]interface{} {
+	if colsMap == nil || cols == nil {
+		return colsMap
+	}
+	picked := map[string]interface{}{}
+	for _, col := range cols {
+		if v, ok := colsMap[col]; ok {
+			picked[col] = v
+		}
+	}
+	return picked
+}
+
+// pkOf returns the pk value of the model, nil if unknown
+func pkOf(manager *Manager, m isModel) interface{} {
+	if manager.pk != "" {
+		return manager.fieldMap[manager.pk].Interface()
+	}
+	if holder, ok := m.(idHolder); ok {
+		_, id := holder.Identity()
+		return id
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package om
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// Cursor marks a row by the values of its ORDER BY columns,
+// it's used to seek pages by keyset instead of a deep `OFFSET`
+//
+// Example:
+//
+//	var books []Book
+//	s := db.Tb("book").Select().OrderBy("created_at DESC", "id DESC").Limit(20)
+//	err := s.After(cursor).All(&books)
+//	// hand the opaque string to the client for the next page, if any
+//	next, more, err := s.NextCursor()
+//	if more {
+//		resp.Next = next.Encode()
+//	}
+type Cursor struct {
+	Values []interface{}
+}
+
+// timeKey wraps `time.Time` values in encoded cursors, so they are decoded as times
+const timeKey = "$time"
+
+// Encode encodes the cursor to an opaque url safe string
+func (c *Cursor) Encode() string {
+	if c == nil {
+		return ""
+	}
+	values := make([]interface{}, len(c.Values))
+	for i, v := range c.Values {
+		if tm, ok := v.(time.Time); ok {
+			v = map[string]string{timeKey: tm.Format(time.RFC3339Nano)}
+		}
+		values[i] = v
+	}
+	bs, _ := json.Marshal(values)
+	return base64.RawURLEncoding.EncodeToString(bs)
+}
+
+// DecodeCursor decodes a string made by `Cursor.Encode`,
+// an empty string decodes to nil which means the first page
+func DecodeCursor(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	bs, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor:%v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(bs))
+	decoder.UseNumber()
+	var values []interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, fmt.Errorf("invalid cursor:%v", err)
+	}
+	for i, v := range values {
+		switch v := v.(type) {
+		case json.Number:
+			// keep integers exact
+			if iv, err := v.Int64(); err == nil {
+				values[i] = iv
+			} else {
+				values[i], _ = v.Float64()
+			}
+		case map[string]interface{}:
+			s, ok := v[timeKey].(string)
+			if !ok || len(v) != 1 {
+				return nil, fmt.Errorf("invalid cursor value:%v", v)
+			}
+			tm, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cursor:%v", err)
+			}
+			values[i] = tm
+		}
+	}
+	return &Cursor{Values: values}, nil
+}
+
+// After seeks the rows after the cursor in the order of the select,
+// the ORDER BY columns should be NOT NULL and unique as a whole,
+// a nil cursor means the first page
+func (s *Select) After(c *Cursor) *Select {
+	s.cursor = c
+	s.before = false
+	return s
+}
+
+// Before seeks the rows before the cursor in the order of the select,
+// rows are still returned in the order of the select
+func (s *Select) Before(c *Cursor) *Select {
+	s.cursor = c
+	s.before = c != nil
+	return s
+}
+
+// NextCursor returns the cursor of the last row scanned by `All`,
+// more is false at the end of data, which is an empty page or a page
+// shorter than the limit seeked after the cursor, the cursor is nil for an empty page,
+// it fails if the ORDER BY columns aren't scanned to the model
+func (s *Select) NextCursor() (c *Cursor, more bool, err error) {
+	c, n, err := s.rowCursor(false)
+	if err != nil || n == 0 {
+		return nil, false, err
+	}
+	if s.before {
+		// the row of the cursor is after the page
+		return c, true, nil
+	}
+	return c, s.limit >= 0 && n >= s.limit, nil
+}
+
+// PrevCursor returns the cursor of the first row scanned by `All`,
+// more is false at the start of data like `NextCursor`
+func (s *Select) PrevCursor() (c *Cursor, more bool, err error) {
+	c, n, err := s.rowCursor(true)
+	if err != nil || n == 0 {
+		return nil, false, err
+	}
+	if s.before {
+		return c, s.limit >= 0 && n >= s.limit, nil
+	}
+	// the row of the cursor is before the page
+	return c, s.cursor != nil, nil
+}
+
+// seekOrders returns the orders to query with,
+// the orders are reversed to seek rows before the cursor
+func (s *Select) seekOrders() []*Order {
+	if !s.before {
+		return s.orders
+	}
+	orders := make([]*Order, len(s.orders))
+	for i, o := range s.orders {
+		flipped := *o
+		flipped.desc = !o.desc
+		switch o.nulls {
+		case nullsFirst:
+			flipped.nulls = nullsLast
+		case nullsLast:
+			flipped.nulls = nullsFirst
+		}
+		orders[i] = &flipped
+	}
+	return orders
+}
+
+// keysetExpr makes the condition of rows after the cursor in the orders,
+// `(a, b) > (?, ?)` if the dialect can compare row values,
+// otherwise `a > ? OR (a = ? AND b > ?)`
+func keysetExpr(d Dialect, orders []*Order, c *Cursor) (isExpr, error) {
+	if len(orders) == 0 {
+		return nil, errors.New("cursor needs ORDER BY columns")
+	}
+	if len(orders) != len(c.Values) {
+		return nil, fmt.Errorf("cursor has %d values for %d ORDER BY columns",
+			len(c.Values), len(orders))
+	}
+	sameDir := true
+	cols := make([]string, len(orders))
+	ops := make([]string, len(orders))
+	for i, o := range orders {
+		if len(o.args) > 0 {
+			return nil, fmt.Errorf("cursor can't seek on order expression:%s", o.expr)
+		}
+		cols[i] = o.expr
+		ops[i] = opGt
+		if o.desc {
+			ops[i] = opLt
+		}
+		sameDir = sameDir && ops[i] == ops[0]
+	}
+	if len(orders) == 1 {
+		return Raw(fmt.Sprintf("%s %s ?", cols[0], ops[0]), c.Values[0]), nil
+	}
+	if sameDir && d.SupportsRowValues() {
+		holders := strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ")
+		q := fmt.Sprintf("(%s) %s (%s)", strings.Join(cols, ", "), ops[0], holders)
+		return Raw(q, c.Values...), nil
+	}
+	var ors []isExpr
+	for i := range orders {
+		var ands []isExpr
+		for j := 0; j < i; j++ {
+			ands = append(ands, Raw(fmt.Sprintf("%s = ?", cols[j]), c.Values[j]))
+		}
+		ands = append(ands, Raw(fmt.Sprintf("%s %s ?", cols[i], ops[i]), c.Values[i]))
+		ors = append(ors, And(ands...))
+	}
+	return Or(ors...), nil
+}
+
+// afterScan reverses rows seeked before the cursor back to the order of the select,
+// and keeps the rows to make cursors
+func (s *Select) afterScan(models interface{}) {
+	s.scanned = models
+	if !s.before {
+		return
+	}
+	v := reflect.Indirect(reflect.ValueOf(models))
+	swap := reflect.Swapper(v.Interface())
+	for i, j := 0, v.Len()-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+}
+
+// rowCursor makes the cursor of the first or last scanned row,
+// n is the count of rows scanned
+func (s *Select) rowCursor(first bool) (c *Cursor, n int, err error) {
+	if s.scanned == nil {
+		return nil, 0, errors.New("no rows scanned by All")
+	}
+	v := reflect.Indirect(reflect.ValueOf(s.scanned))
+	if v.Kind() != reflect.Slice || v.Len() == 0 {
+		return nil, 0, nil
+	}
+	if len(s.orders) == 0 {
+		return nil, 0, errors.New("cursor needs ORDER BY columns")
+	}
+	row := v.Index(v.Len() - 1)
+	if first {
+		row = v.Index(0)
+	}
+	row = reflect.Indirect(row)
+	tpMap := modelsMapper.TypeMap(row.Type())
+	values := make([]interface{}, len(s.orders))
+	for i, o := range s.orders {
+		// `b.name` or `"name"` is scanned to the field of `name`
+		col := o.expr
+		if i := strings.LastIndex(col, "."); i >= 0 {
+			col = col[i+1:]
+		}
+		col = strings.Trim(col, "`\"")
+		info, ok := tpMap.Names[col]
+		if !ok || len(o.args) > 0 {
+			return nil, 0, fmt.Errorf("cursor can't get the value of order column:%s", o.expr)
+		}
+		values[i] = reflectx.FieldByIndexesReadOnly(row, info.Index).Interface()
+	}
+	return &Cursor{Values: values}, v.Len(), nil
+}
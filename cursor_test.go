@@ -0,0 +1,58 @@
+package om
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCursor_Encode(t *testing.T) {
+	c := &Cursor{Values: []interface{}{int64(99), "Python", 1.5}}
+	decoded, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Errorf("err:%v", err)
+	}
+	if !reflect.DeepEqual(decoded, c) {
+		t.Errorf("expect %+v, got:%+v", c, decoded)
+	}
+	if _, err := DecodeCursor("not a cursor"); err == nil {
+		t.Error("expect err on invalid cursor")
+	}
+
+	// times are decoded as times
+	at := time.Date(2017, 5, 1, 8, 30, 0, 123, time.UTC)
+	decoded, err = DecodeCursor((&Cursor{Values: []interface{}{at, int64(1)}}).Encode())
+	if err != nil {
+		t.Errorf("err:%v", err)
+	}
+	if tm, ok := decoded.Values[0].(time.Time); !ok || !tm.Equal(at) {
+		t.Errorf("expect %v, got:%#v", at, decoded.Values[0])
+	}
+}
+
+func TestKeysetExpr(t *testing.T) {
+	c := &Cursor{Values: []interface{}{99, 3}}
+	e, err := keysetExpr(SQLite, []*Order{Desc("tag"), Desc("id")}, c)
+	if err != nil {
+		t.Errorf("err:%v", err)
+	}
+	q, args, _ := e.toSql()
+	if q != "(tag, id) < (?, ?)" || !reflect.DeepEqual(args, []interface{}{99, 3}) {
+		t.Errorf("got:%s, %v", q, args)
+	}
+
+	// mixed directions are expanded
+	e, err = keysetExpr(SQLite, []*Order{Desc("tag"), Asc("id")}, c)
+	if err != nil {
+		t.Errorf("err:%v", err)
+	}
+	q, args, _ = e.toSql()
+	expect := "tag < ? OR ((tag = ?) AND (id > ?))"
+	if q != expect || !reflect.DeepEqual(args, []interface{}{99, 99, 3}) {
+		t.Errorf("expect %s, got:%s, %v", expect, q, args)
+	}
+
+	if _, err := keysetExpr(SQLite, []*Order{Asc("id")}, c); err == nil {
+		t.Error("expect err on mismatched cursor values")
+	}
+}
@@ -0,0 +1,334 @@
+package om
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Dialect hides the SQL differences between databases,
+// the dialect is chosen when `NewDB` is called
+//
+// Builders always write `?` as placeholder, the dialect rewrites
+// them right before the query is sent to the database
+type Dialect interface {
+	// Name returns the name of the dialect
+	Name() string
+	// Rebind rewrites `?` placeholders to the bindvar style of the dialect
+	Rebind(query string) string
+	// Quote quotes an identifier, such as a table or column name
+	Quote(ident string) string
+	// LimitOffset returns the LIMIT/OFFSET clause,
+	// limit < 0 means no limit and offset <= 0 means no offset
+	LimitOffset(limit int, offset int) string
+	// Returning returns the clause appended to an INSERT to fetch the inserted pk,
+	// empty means the pk comes back from `sql.Result.LastInsertId`, or no pk if pk is empty
+	Returning(pk string) string
+	// SupportsNullsOrder tells if `NULLS FIRST/LAST` can be used in ORDER BY
+	SupportsNullsOrder() bool
+	// SupportsRowValues tells if row values can be compared, e.g. `(a, b) > (?, ?)`
+	SupportsRowValues() bool
+	// MaxPlaceholders is the most placeholders a statement can bind
+	MaxPlaceholders() int
+	// Savepoint returns the sql to create a savepoint
+	Savepoint(name string) string
+	// RollbackToSavepoint returns the sql to roll back to a savepoint
+	RollbackToSavepoint(name string) string
+	// ReleaseSavepoint returns the sql to release a savepoint
+	ReleaseSavepoint(name string) string
+	// IsRetryable tells if the transaction failed by the error can be retried,
+	// such as deadlocks and serialization failures
+	IsRetryable(err error) bool
+}
+
+var (
+	// MySQL dialect, `?` placeholders and back-quoted identifiers
+	MySQL Dialect = &mysqlDialect{}
+	// Postgres dialect, `$N` placeholders and `RETURNING` to get inserted ids
+	Postgres Dialect = &postgresDialect{}
+	// SQLite dialect, `?` placeholders and double-quoted identifiers
+	SQLite Dialect = &sqliteDialect{}
+)
+
+var dialects = map[string]Dialect{
+	"mysql":    MySQL,
+	"postgres": Postgres,
+	"pgx":      Postgres,
+	"sqlite3":  SQLite,
+	"sqlite":   SQLite,
+}
+
+// RegisterDialect binds a dialect to a `database/sql` driver name,
+// so `NewDB` picks it for databases opened with the driver
+func RegisterDialect(driverName string, d Dialect) {
+	dialects[driverName] = d
+}
+
+// dialectOf returns the dialect registered for the driver,
+// MySQL is used for unknown drivers
+func dialectOf(driverName string) Dialect {
+	if d, ok := dialects[driverName]; ok {
+		return d
+	}
+	return MySQL
+}
+
+// sqlStater is implemented by errors of postgres drivers, such as lib/pq and pgx
+type sqlStater interface {
+	SQLState() string
+}
+
+// sqlState returns the SQLSTATE code of the error, empty if unknown
+func sqlState(err error) string {
+	var e sqlStater
+	if errors.As(err, &e) {
+		return e.SQLState()
+	}
+	return ""
+}
+
+var identRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// quoteIdent quotes plain (maybe dotted) identifiers with the dialect,
+// anything else such as `COUNT(*)` or `a.*` is an expression and kept as it is
+func quoteIdent(d Dialect, ident string) string {
+	if !identRe.MatchString(ident) {
+		return ident
+	}
+	parts := strings.Split(ident, ".")
+	for i, part := range parts {
+		parts[i] = d.Quote(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+type mysqlDialect struct {
+}
+
+func (d *mysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (d *mysqlDialect) Rebind(query string) string {
+	return query
+}
+
+func (d *mysqlDialect) Quote(ident string) string {
+	return "`" + strings.Replace(ident, "`", "``", -1) + "`"
+}
+
+func (d *mysqlDialect) LimitOffset(limit int, offset int) string {
+	if limit < 0 && offset <= 0 {
+		return ""
+	}
+	// mysql can't use OFFSET without LIMIT
+	if limit < 0 {
+		return fmt.Sprintf("LIMIT 18446744073709551615 OFFSET %d", offset)
+	}
+	if offset <= 0 {
+		return fmt.Sprintf("LIMIT %d", limit)
+	}
+	return fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+}
+
+func (d *mysqlDialect) Returning(pk string) string {
+	return ""
+}
+
+func (d *mysqlDialect) SupportsNullsOrder() bool {
+	return false
+}
+
+func (d *mysqlDialect) SupportsRowValues() bool {
+	return true
+}
+
+func (d *mysqlDialect) MaxPlaceholders() int {
+	return 65535
+}
+
+func (d *mysqlDialect) Savepoint(name string) string {
+	return fmt.Sprintf("SAVEPOINT %s", d.Quote(name))
+}
+
+func (d *mysqlDialect) RollbackToSavepoint(name string) string {
+	return fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", d.Quote(name))
+}
+
+func (d *mysqlDialect) ReleaseSavepoint(name string) string {
+	return fmt.Sprintf("RELEASE SAVEPOINT %s", d.Quote(name))
+}
+
+// IsRetryable is true for deadlock(1213) and lock wait timeout(1205) errors
+func (d *mysqlDialect) IsRetryable(err error) bool {
+	var e *mysql.MySQLError
+	if !errors.As(err, &e) {
+		return false
+	}
+	return e.Number == 1213 || e.Number == 1205
+}
+
+type postgresDialect struct {
+}
+
+func (d *postgresDialect) Name() string {
+	return "postgres"
+}
+
+// Rebind rewrites `?` to `$1..$N`, `?` in quoted strings or identifiers are kept,
+// it must run after `parseINSpec` so expanded slices are numbered too
+func (d *postgresDialect) Rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	sb := bytes.NewBuffer(make([]byte, 0, len(query)+10))
+	var quote rune
+	n := 0
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			// inside a quoted string or identifier
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			sb.WriteString("$")
+			sb.WriteString(strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+func (d *postgresDialect) Quote(ident string) string {
+	return `"` + strings.Replace(ident, `"`, `""`, -1) + `"`
+}
+
+func (d *postgresDialect) LimitOffset(limit int, offset int) string {
+	var blocks []string
+	if limit >= 0 {
+		blocks = append(blocks, fmt.Sprintf("LIMIT %d", limit))
+	}
+	if offset > 0 {
+		blocks = append(blocks, fmt.Sprintf("OFFSET %d", offset))
+	}
+	return strings.Join(blocks, " ")
+}
+
+func (d *postgresDialect) Returning(pk string) string {
+	if pk == "" {
+		return ""
+	}
+	return fmt.Sprintf("RETURNING %s", d.Quote(pk))
+}
+
+func (d *postgresDialect) SupportsNullsOrder() bool {
+	return true
+}
+
+func (d *postgresDialect) SupportsRowValues() bool {
+	return true
+}
+
+func (d *postgresDialect) MaxPlaceholders() int {
+	return 65535
+}
+
+func (d *postgresDialect) Savepoint(name string) string {
+	return fmt.Sprintf("SAVEPOINT %s", d.Quote(name))
+}
+
+func (d *postgresDialect) RollbackToSavepoint(name string) string {
+	return fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", d.Quote(name))
+}
+
+func (d *postgresDialect) ReleaseSavepoint(name string) string {
+	return fmt.Sprintf("RELEASE SAVEPOINT %s", d.Quote(name))
+}
+
+// IsRetryable is true for serialization_failure(40001) and deadlock_detected(40P01)
+func (d *postgresDialect) IsRetryable(err error) bool {
+	switch sqlState(err) {
+	case "40001", "40P01":
+		return true
+	}
+	return false
+}
+
+type sqliteDialect struct {
+}
+
+func (d *sqliteDialect) Name() string {
+	return "sqlite3"
+}
+
+func (d *sqliteDialect) Rebind(query string) string {
+	return query
+}
+
+func (d *sqliteDialect) Quote(ident string) string {
+	return `"` + strings.Replace(ident, `"`, `""`, -1) + `"`
+}
+
+func (d *sqliteDialect) LimitOffset(limit int, offset int) string {
+	if limit < 0 && offset <= 0 {
+		return ""
+	}
+	// sqlite needs LIMIT before OFFSET, -1 means no limit
+	if offset <= 0 {
+		return fmt.Sprintf("LIMIT %d", limit)
+	}
+	return fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+}
+
+func (d *sqliteDialect) Returning(pk string) string {
+	return ""
+}
+
+// SupportsNullsOrder is true since sqlite 3.30
+func (d *sqliteDialect) SupportsNullsOrder() bool {
+	return true
+}
+
+// SupportsRowValues is true since sqlite 3.15
+func (d *sqliteDialect) SupportsRowValues() bool {
+	return true
+}
+
+// MaxPlaceholders is 999 by default before sqlite 3.32 which raised it to 32766,
+// the smaller one is safe for both
+func (d *sqliteDialect) MaxPlaceholders() int {
+	return 999
+}
+
+func (d *sqliteDialect) Savepoint(name string) string {
+	return fmt.Sprintf("SAVEPOINT %s", d.Quote(name))
+}
+
+// RollbackToSavepoint keeps the savepoint, it's released later as mysql and postgres do
+func (d *sqliteDialect) RollbackToSavepoint(name string) string {
+	return fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", d.Quote(name))
+}
+
+func (d *sqliteDialect) ReleaseSavepoint(name string) string {
+	return fmt.Sprintf("RELEASE SAVEPOINT %s", d.Quote(name))
+}
+
+// IsRetryable is true for SQLITE_BUSY and SQLITE_LOCKED errors
+func (d *sqliteDialect) IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") ||
+		strings.Contains(msg, "database table is locked")
+}
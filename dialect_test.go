@@ -0,0 +1,123 @@
+package om
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestDialect_LimitOffset(t *testing.T) {
+	cases := []struct {
+		d      Dialect
+		limit  int
+		offset int
+		expect string
+	}{
+		{MySQL, 10, 0, "LIMIT 10"},
+		{MySQL, 10, 20, "LIMIT 10 OFFSET 20"},
+		{MySQL, -1, 20, "LIMIT 18446744073709551615 OFFSET 20"},
+		{MySQL, -1, 0, ""},
+		{Postgres, 10, 20, "LIMIT 10 OFFSET 20"},
+		{Postgres, -1, 20, "OFFSET 20"},
+		{SQLite, 10, 20, "LIMIT 10 OFFSET 20"},
+		{SQLite, -1, 20, "LIMIT -1 OFFSET 20"},
+	}
+	for _, c := range cases {
+		got := c.d.LimitOffset(c.limit, c.offset)
+		if got != c.expect {
+			t.Errorf("%s: expect %q, got:%q", c.d.Name(), c.expect, got)
+		}
+	}
+}
+
+func TestDialect_Rebind(t *testing.T) {
+	q := "SELECT a FROM t WHERE a = ? AND b IN (?,?)"
+	if got := MySQL.Rebind(q); got != q {
+		t.Errorf("expect mysql keeps `?`, got:%s", got)
+	}
+	expect := "SELECT a FROM t WHERE a = $1 AND b IN ($2,$3)"
+	if got := Postgres.Rebind(q); got != expect {
+		t.Errorf("expect %s, got:%s", expect, got)
+	}
+}
+
+func TestQuoteIdent(t *testing.T) {
+	if got := quoteIdent(MySQL, "b.name"); got != "`b`.`name`" {
+		t.Errorf("expect quoted dotted ident, got:%s", got)
+	}
+	if got := quoteIdent(Postgres, "name"); got != `"name"` {
+		t.Errorf("expect quoted ident, got:%s", got)
+	}
+	// expressions are kept as they are
+	for _, expr := range []string{"COUNT(*)", "b.*", "name AS n"} {
+		if got := quoteIdent(SQLite, expr); got != expr {
+			t.Errorf("expect %s kept, got:%s", expr, got)
+		}
+	}
+}
+
+func TestPostgres_RebindQuoted(t *testing.T) {
+	q := `UPDATE t SET "a?"=?, b=? WHERE c = 'who?' AND d = ?`
+	expect := `UPDATE t SET "a?"=$1, b=$2 WHERE c = 'who?' AND d = $3`
+	if got := Postgres.Rebind(q); got != expect {
+		t.Errorf("expect %s, got:%s", expect, got)
+	}
+
+	// slices are expanded before rebinding
+	q = "SELECT a FROM t WHERE b = ? AND c IN ?"
+	args := []interface{}{1, []int{2, 3}}
+	err := parseINSpec(&q, &args)
+	if err != nil {
+		t.Errorf("err:%v", err)
+	}
+	expect = "SELECT a FROM t WHERE b = $1 AND c IN ($2,$3)"
+	if got := Postgres.Rebind(q); got != expect {
+		t.Errorf("expect %s, got:%s", expect, got)
+	}
+}
+
+type testPgError struct {
+	code string
+}
+
+func (e *testPgError) Error() string {
+	return "pq: " + e.code
+}
+
+func (e *testPgError) SQLState() string {
+	return e.code
+}
+
+func TestDialect_IsRetryable(t *testing.T) {
+	cases := []struct {
+		d      Dialect
+		err    error
+		expect bool
+	}{
+		{MySQL, &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, true},
+		{MySQL, fmt.Errorf("wrapped: %w", &mysql.MySQLError{Number: 1205}), true},
+		{MySQL, &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, false},
+		{MySQL, errors.New("Error 1213: not a driver error"), false},
+		{Postgres, &testPgError{"40001"}, true},
+		{Postgres, fmt.Errorf("wrapped: %w", &testPgError{"40P01"}), true},
+		{Postgres, &testPgError{"23505"}, false},
+		{SQLite, errors.New("database is locked"), true},
+		{SQLite, nil, false},
+	}
+	for _, c := range cases {
+		if got := c.d.IsRetryable(c.err); got != c.expect {
+			t.Errorf("%s: expect %v on %v, got:%v", c.d.Name(), c.expect, c.err, got)
+		}
+	}
+}
+
+func TestDialect_Returning(t *testing.T) {
+	if got := Postgres.Returning("id"); got != `RETURNING "id"` {
+		t.Errorf("expect RETURNING \"id\", got:%s", got)
+	}
+	if got := Postgres.Returning(""); got != "" {
+		t.Errorf("expect no RETURNING without pk, got:%s", got)
+	}
+}
@@ -6,12 +6,19 @@ import (
 	"errors"
 )
 
-// Tracking struct is the base flag to make a struct be tracked
+// Tracking struct is the base flag to make a struct be tracked,
+// models loaded by `Select.Get/All` are watched automatically,
+// so `Tables.Update` only updates the changed columns
 //
 // What fields can be tracked ?
 // 1.Comparable Types
 // 2.Implement the Comparable interface
-// 3.un exported field won't be tracked
+// 3.Slices, maps, arrays, interfaces, pointers to structs and structs with them
+//   are deep copied and compared by `reflect.DeepEqual`
+// 4.un exported field won't be tracked, nor the fields promoted from it
+// 5.fields tagged `om:"-"` won't be tracked, such as expensive deep fields
+//
+// columns of fields not tracked are always updated
 //
 // Example:
 //
@@ -29,7 +36,13 @@ import (
 //              // Comparable interface can be tracked
 //		Address *Address
 //		Parent *Parent
+//
+//              // deep tracked
 //		Data []string
+//		Labels map[string]string
+//
+//              // opt out
+//		Blob []byte `om:"-"`
 //	}
 //
 type Tracking struct {
@@ -60,31 +73,86 @@ type Comparable interface {
 	Equal(interface{})bool
 }
 
+const (
+	// trackTag is the tag to opt out fields by `om:"-"`
+	trackTag = "om"
+)
+
+// ways to find out changes of a field
+const (
+	// compare by `==`
+	cmpEqual = iota
+	// compare by `Comparable.Equal`
+	cmpComparable
+	// snapshot by deep copy, compare by `reflect.DeepEqual`
+	cmpDeep
+)
+
+var comparableType = reflect.TypeOf((*Comparable)(nil)).Elem()
+
+// fieldInfo is a tracked field of the type, values are read from the struct diffed
 type fieldInfo struct {
 	fieldStruct reflect.StructField
-	fieldValue reflect.Value
-	isComparable bool
+	// column mapped by the `db` tag, empty if none
+	column string
+	cmp int
 }
 
 func (f *fieldInfo) Name() string {
 	return f.fieldStruct.Name
 }
 
-func (f *fieldInfo) Val() interface{} {
-	return f.fieldValue.Interface()
+// valIn returns the value of the field in the struct v
+func (f *fieldInfo) valIn(v reflect.Value) interface{} {
+	return v.Field(f.fieldStruct.Index[0]).Interface()
+}
+
+// snapshotVal returns the value of the field in the struct v kept in the snapshot,
+// it is a deep copy for deep tracked fields
+func (f *fieldInfo) snapshotVal(v reflect.Value) interface{} {
+	if f.cmp == cmpDeep {
+		return deepCopy(v.Field(f.fieldStruct.Index[0])).Interface()
+	}
+	return f.valIn(v)
+}
+
+// copyOf copies the value of the field in the snapshot
+func (f *fieldInfo) copyOf(v interface{}) interface{} {
+	if f.cmp != cmpDeep || v == nil {
+		return v
+	}
+	return deepCopy(reflect.ValueOf(v)).Interface()
+}
+
+// changed tells if the field of the struct sv is changed from the value of the snapshot
+func (f *fieldInfo) changed(sv reflect.Value, old interface{}) bool {
+	v := f.valIn(sv)
+	switch f.cmp {
+	case cmpComparable:
+		return !v.(Comparable).Equal(old)
+	case cmpDeep:
+		return !reflect.DeepEqual(old, v)
+	}
+	return old != v
 }
 
 type isDirtyTracker interface {
 	// track starts a watch point to begin tracking
 	track(isDirtyTracker) error
-	// dirtyFields returns dirty fields map `{field_name}=>{field_value}`
-	dirtyFields() (map[string]interface{}, error)
+	// dirtyFields returns dirty fields map `{field_name}=>{field_value}` of the target,
+	// which is the model watched or a copy of it
+	dirtyFields(target isDirtyTracker) (map[string]interface{}, error)
+	// changes returns the changes of the target from the snapshot in struct field order
+	changes(target isDirtyTracker) ([]Change, error)
+	// reset reverts the fields of the target to the snapshot
+	reset(target isDirtyTracker) error
+	// tracks tells if the top level field is tracked
+	tracks(field string) bool
 }
 
 type tracker struct {
 	holdType reflect.Type
 	snapshot map[string]interface{}
-	target   isDirtyTracker
 	members  [] *fieldInfo
 }
 
@@ -102,62 +170,265 @@ func (t *tracker) track(target isDirtyTracker) error  {
 	tp = reflect.Indirect(tp)
 	te := tp.Type()
 
-	t.target = target
-	// type info
 	if t.holdType != te {
 		t.holdType = te
 		t.members = nil
-		for i := 0; i < tp.NumField(); i++ {
-			fieldValue := tp.Field(i)
+		// columns of the top level fields
+		columns := map[int]string{}
+		for _, fi := range taggedFields(modelsMapper.TypeMap(te)) {
+			if len(fi.Index) == 1 {
+				columns[fi.Index[0]] = fi.Path
+			}
+		}
+		for i := 0; i < te.NumField(); i++ {
 			fieldStruct := te.Field(i)
 			// never track un exported field
-			if !fieldValue.CanInterface() {
+			if fieldStruct.PkgPath != "" {
 				continue
 			}
-			face := fieldValue.Interface()
-			if _, ok := face.(Comparable); ok {
-				t.members = append(t.members,
-					&fieldInfo{fieldStruct, fieldValue, true})
+			if fieldStruct.Tag.Get(trackTag) == "-" {
 				continue
 			}
-			if fieldValue.Type().Comparable() {
-				t.members = append(t.members,
-					&fieldInfo{fieldStruct, fieldValue, false})
+			if cmp, ok := cmpOf(fieldStruct.Type); ok {
+				t.members = append(t.members, &fieldInfo{fieldStruct, columns[i], cmp})
 			}
 		}
 	}
-	t.newSnapshot()
+	t.newSnapshot(tp)
 	return nil
 }
 
-// dirtyFields returns dirty fields map `{field_name}=>{field_value}`
-// return value may be nil
-func (t *tracker) dirtyFields() (map[string]interface{}, error) {
+// cmpOf returns the way to compare values of the type,
+// false if the type can't be tracked
+func cmpOf(tp reflect.Type) (int, bool) {
+	if tp.Implements(comparableType) {
+		return cmpComparable, true
+	}
+	switch tp.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return 0, false
+	case reflect.Interface:
+		// `==` panics on interfaces holding slices or maps
+		return cmpDeep, true
+	case reflect.Ptr:
+		// fields changed through the pointer are found by the pointee
+		if tp.Elem().Kind() == reflect.Struct {
+			return cmpDeep, true
+		}
+	case reflect.Struct:
+		// the embedded tracker itself
+		if tp == reflect.TypeOf(Tracking{}) {
+			return 0, false
+		}
+	}
+	if tp.Comparable() {
+		return cmpEqual, true
+	}
+	return cmpDeep, true
+}
+
+// deepCopy copies slices, maps, arrays, pointers and exported fields of structs,
+// so changes in place can be found by comparing with the copy,
+// values with pointer cycles can't be copied
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMap(v.Type())
+		for _, k := range v.MapKeys() {
+			c.SetMapIndex(k, deepCopy(v.MapIndex(k)))
+		}
+		return c
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		// un exported fields are copied shallowly
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	}
+	return v
+}
+
+// valueOf returns the struct of the target, fields are read from it rather than the model watched,
+// so a copy of the model, e.g. an element copied by `range`, is diffed on itself
+func (t *tracker) valueOf(target isDirtyTracker) (reflect.Value, error) {
 	if t.snapshot == nil {
-		return nil, errors.New("make snapshot firtly")
+		return reflect.Value{}, errors.New("make snapshot firtly")
+	}
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Type() != t.holdType {
+		return reflect.Value{}, fmt.Errorf("target %T is not a pointer of the watched %v", target, t.holdType)
+	}
+	return v.Elem(), nil
+}
+
+// dirtyFields returns dirty fields map `{field_name}=>{field_value}` of the target
+// return value may be nil
+func (t *tracker) dirtyFields(target isDirtyTracker) (map[string]interface{}, error) {
+	v, err := t.valueOf(target)
+	if err != nil {
+		return nil, err
 	}
 	var dirty = map[string]interface{}{}
 	for _, info := range t.members {
-		v := info.Val()
-		if info.isComparable {
-			if !v.(Comparable).Equal(t.snapshot[info.Name()]) {
-				dirty[info.Name()] = v
-			}
-		}else{
-			if t.snapshot[info.Name()] != v {
-				dirty[info.Name()] = v
-			}
+		if info.changed(v, t.snapshot[info.Name()]) {
+			dirty[info.Name()] = info.valIn(v)
 		}
 	}
 	return dirty, nil
 }
 
-func (t *tracker) newSnapshot() {
+func (t *tracker) newSnapshot(v reflect.Value) {
 	// new snapshot
 	t.snapshot = make(map[string]interface{})
 	for _, info := range t.members {
-		t.snapshot[info.Name()] = info.Val()
+		t.snapshot[info.Name()] = info.snapshotVal(v)
+	}
+}
+
+// trackAll watches the elements of the slice which embed `Tracking`,
+// elements may be structs or pointers to structs
+func trackAll(models interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(models))
+	if v.Kind() != reflect.Slice {
+		return nil
+	}
+	for i := 0; i < v.Len(); i++ {
+		el := v.Index(i)
+		if el.Kind() != reflect.Ptr {
+			el = el.Addr()
+		} else if el.IsNil() {
+			continue
+		}
+		tracker, ok := el.Interface().(isDirtyTracker)
+		if !ok {
+			// elements are in the same type
+			return nil
+		}
+		if err := tracker.track(tracker); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Change is a field changed from the snapshot,
+// Column is empty if the field isn't mapped to a column by the `db` tag
+type Change struct {
+	Field  string
+	Column string
+	Old    interface{}
+	New    interface{}
+}
+
+func (t *tracker) changes(target isDirtyTracker) ([]Change, error) {
+	v, err := t.valueOf(target)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, info := range t.members {
+		old := t.snapshot[info.Name()]
+		if !info.changed(v, old) {
+			continue
+		}
+		changes = append(changes, Change{
+			Field:  info.Name(),
+			Column: info.column,
+			// keep the snapshot from changes by the caller
+			Old: info.copyOf(old),
+			New: info.valIn(v),
+		})
+	}
+	return changes, nil
+}
+
+func (t *tracker) tracks(field string) bool {
+	for _, info := range t.members {
+		if info.Name() == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *tracker) reset(target isDirtyTracker) error {
+	v, err := t.valueOf(target)
+	if err != nil {
+		return err
+	}
+	for _, info := range t.members {
+		old := reflect.ValueOf(info.copyOf(t.snapshot[info.Name()]))
+		if !old.IsValid() {
+			old = reflect.Zero(info.fieldStruct.Type)
+		}
+		v.Field(info.fieldStruct.Index[0]).Set(old)
+	}
+	return nil
+}
+
+// Changes returns the fields changed since the model is watched
+// with the old and new values, in struct field order
+//
+// Example:
+//	changes, err := om.Changes(&car)
+//	for _, c := range changes {
+//		fmt.Printf("%s(%s): %v => %v\n", c.Field, c.Column, c.Old, c.New)
+//	}
+func Changes(t isDirtyTracker) ([]Change, error) {
+	return t.changes(t)
+}
+
+// Reset reverts the model to the snapshot taken when it's watched,
+// a copy of the watched model is reverted on itself
+func Reset(t isDirtyTracker) error {
+	return t.reset(t)
+}
+
+// IsDirty tells if the field is changed since the model is watched,
+// false if the model isn't watched or the field isn't tracked
+func IsDirty(t isDirtyTracker, field string) bool {
+	dirty, err := t.dirtyFields(t)
+	if err != nil {
+		return false
 	}
+	_, ok := dirty[field]
+	return ok
 }
 
 func Watch(t isDirtyTracker)  {
@@ -168,7 +439,9 @@ func Debug(t isDirtyTracker)  {
 	fmt.Printf("\ndebug>>%+v\n", t)
 }
 
+// GetDirtyFields returns the new values of dirty fields,
+// nil if the model isn't watched, see `Changes` for old values and the error
 func GetDirtyFields(t isDirtyTracker) map[string]interface{} {
-	dirty, _ := t.dirtyFields()
+	dirty, _ := t.dirtyFields(t)
 	return dirty
 }
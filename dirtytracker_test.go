@@ -0,0 +1,156 @@
+package om
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type tProfile struct {
+	Tags  []string
+	Extra map[string]interface{}
+}
+
+type tAddress struct {
+	City string
+}
+
+type tTrackedUser struct {
+	Tracking
+
+	id      int
+	Name    string
+	Tags    []string
+	Labels  map[string]string
+	Profile tProfile
+	Address *tAddress
+	Any     interface{}
+	Blob    []byte `om:"-"`
+}
+
+func dirtyNames(t *testing.T, u *tTrackedUser) []string {
+	dirty, err := u.dirtyFields(u)
+	if err != nil {
+		t.Fatalf("got err:%v", err)
+	}
+	names := []string{}
+	for name := range dirty {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestTracker_deep(t *testing.T) {
+	u := &tTrackedUser{
+		Name:    "Tom",
+		Tags:    []string{"a"},
+		Labels:  map[string]string{"k": "v"},
+		Profile: tProfile{Tags: []string{"x"}, Extra: map[string]interface{}{"n": []int{1}}},
+		Address: &tAddress{City: "Paris"},
+		Any:     []int{1},
+		Blob:    []byte("blob"),
+	}
+	Watch(u)
+	if names := dirtyNames(t, u); len(names) != 0 {
+		t.Errorf("expect nothing dirty, got:%v", names)
+	}
+
+	// changes in place are found
+	u.Tags[0] = "b"
+	u.Labels["k"] = "w"
+	u.Profile.Extra["n"].([]int)[0] = 2
+	u.Any.([]int)[0] = 2
+	u.Address.City = "Rome"
+	u.Blob[0] = 'B'
+	expect := []string{"Address", "Any", "Labels", "Profile", "Tags"}
+	if names := dirtyNames(t, u); !reflect.DeepEqual(names, expect) {
+		t.Errorf("expect %v, got:%v", expect, names)
+	}
+
+	Watch(u)
+	u.Tags = append(u.Tags, "c")
+	u.Name = "Jerry"
+	expect = []string{"Name", "Tags"}
+	if names := dirtyNames(t, u); !reflect.DeepEqual(names, expect) {
+		t.Errorf("expect %v, got:%v", expect, names)
+	}
+}
+
+func TestTracker_copied(t *testing.T) {
+	u := &tTrackedUser{Name: "Tom"}
+	Watch(u)
+	copied := *u
+	Watch(&copied)
+	copied.Name = "Jerry"
+	if names := dirtyNames(t, u); len(names) != 0 {
+		t.Errorf("expect the origin is not dirty, got:%v", names)
+	}
+	if names := dirtyNames(t, &copied); !reflect.DeepEqual(names, []string{"Name"}) {
+		t.Errorf("expect Name dirty, got:%v", names)
+	}
+}
+
+type tTrackedCar struct {
+	M
+	Tracking
+	Name string   `db:"car_name"`
+	Tags []string `db:"tags"`
+	Note string
+}
+
+func TestChanges(t *testing.T) {
+	car := &tTrackedCar{Name: "Tesla", Tags: []string{"ev"}, Note: "fast"}
+	if _, err := Changes(car); err == nil {
+		t.Errorf("expect err without snapshot")
+	}
+	Watch(car)
+	car.Name = "BYD"
+	car.Tags[0] = "hybrid"
+	car.Note = "cheap"
+	changes, err := Changes(car)
+	if err != nil {
+		t.Fatalf("got err:%v", err)
+	}
+	expect := []Change{
+		{Field: "Name", Column: "car_name", Old: "Tesla", New: "BYD"},
+		{Field: "Tags", Column: "tags", Old: []string{"ev"}, New: []string{"hybrid"}},
+		{Field: "Note", Column: "", Old: "fast", New: "cheap"},
+	}
+	if !reflect.DeepEqual(changes, expect) {
+		t.Errorf("expect %+v, got:%+v", expect, changes)
+	}
+	if !IsDirty(car, "Tags") || IsDirty(car, "M") || IsDirty(car, "NoSuchField") {
+		t.Errorf("expect only changed fields are dirty")
+	}
+
+	// changing the old values doesn't change the snapshot
+	changes[1].Old.([]string)[0] = "diesel"
+	if err := Reset(car); err != nil {
+		t.Fatalf("got err:%v", err)
+	}
+	if car.Name != "Tesla" || !reflect.DeepEqual(car.Tags, []string{"ev"}) || car.Note != "fast" {
+		t.Errorf("expect reset to the snapshot, got:%+v", car)
+	}
+	if changes, _ := Changes(car); len(changes) != 0 {
+		t.Errorf("expect no changes after reset, got:%+v", changes)
+	}
+}
+
+func TestReset_copied(t *testing.T) {
+	car := tTrackedCar{Name: "Tesla", Note: "fast"}
+	Watch(&car)
+	copied := car
+	copied.Name = "BYD"
+	car.Note = "cheap"
+	if err := Reset(&copied); err != nil {
+		t.Fatalf("got err:%v", err)
+	}
+	// the copy is reverted on itself, the origin is left alone
+	if copied.Name != "Tesla" || copied.Note != "fast" {
+		t.Errorf("expect the copy reset, got:%+v", copied)
+	}
+	if car.Note != "cheap" {
+		t.Errorf("expect the origin not reset, got:%+v", car)
+	}
+}
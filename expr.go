@@ -0,0 +1,263 @@
+package om
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// operators of field expressions
+const (
+	opEq        = "="
+	opNe        = "<>"
+	opGt        = ">"
+	opGe        = ">="
+	opLt        = "<"
+	opLe        = "<="
+	opLike      = "LIKE"
+	opNotLike   = "NOT LIKE"
+	opIn        = "IN"
+	opNotIn     = "NOT IN"
+	opBetween   = "BETWEEN"
+	opIsNull    = "IS NULL"
+	opIsNotNull = "IS NOT NULL"
+	opAnd       = "AND"
+	opOr        = "OR"
+	opNot       = "NOT"
+	opRaw       = "RAW"
+)
+
+// exprInfo holds the operands of an expression,
+// `v` may be a value, an `isField` or the sub expressions
+type exprInfo struct {
+	f  isField
+	v  interface{}
+	op string
+}
+
+type isExpr interface {
+	exprInfo() *exprInfo
+	// toSql compiles the expression to sql with `?` placeholders
+	toSql() (string, []interface{}, error)
+}
+
+// expr is an expression on a field, such as `col > ?`
+type expr struct {
+	info exprInfo
+}
+
+func (e *expr) exprInfo() *exprInfo {
+	return &e.info
+}
+
+func (e *expr) toSql() (string, []interface{}, error) {
+	info := e.info
+	if info.f == nil {
+		return "", nil, fmt.Errorf("no field to apply `%s`", info.op)
+	}
+	col := info.f.FieldInfo().Column
+	if col == "" {
+		return "", nil, fmt.Errorf("no column to apply `%s`", info.op)
+	}
+	switch info.op {
+	case opIsNull, opIsNotNull:
+		return strings.Join([]string{col, info.op}, " "), nil, nil
+	case opIn, opNotIn:
+		v := reflect.ValueOf(info.v)
+		if v.Kind() != reflect.Slice {
+			return "", nil, fmt.Errorf("`%s` expects a slice, got %v", info.op, v.Kind())
+		}
+		// nothing can be IN an empty set
+		if v.Len() == 0 {
+			if info.op == opIn {
+				return "1=0", nil, nil
+			}
+			return "1=1", nil, nil
+		}
+		// the slice is expanded by `parseINSpec`
+		return strings.Join([]string{col, info.op, "?"}, " "), []interface{}{info.v}, nil
+	case opBetween:
+		bounds := info.v.([2]interface{})
+		return strings.Join([]string{col, info.op, "? AND ?"}, " "), bounds[:], nil
+	}
+	if info.op == opEq && info.v == nil {
+		return strings.Join([]string{col, opIsNull}, " "), nil, nil
+	}
+	// compare two columns, such as `a.id = b.author_id`
+	if other, ok := info.v.(isField); ok {
+		return strings.Join([]string{col, info.op, other.FieldInfo().Column}, " "), nil, nil
+	}
+	return strings.Join([]string{col, info.op, "?"}, " "), []interface{}{info.v}, nil
+}
+
+type eqExpr struct {
+	expr
+}
+
+func (eq *eqExpr) eqExpr() *eqExpr {
+	return eq
+}
+
+type isEqExpr interface {
+	isExpr
+	eqExpr() *eqExpr
+}
+
+// logicExpr combines sub expressions with `AND` or `OR`
+type logicExpr struct {
+	expr
+	exprs []isExpr
+}
+
+func (e *logicExpr) toSql() (string, []interface{}, error) {
+	if len(e.exprs) == 0 {
+		// empty AND is true, empty OR is false
+		if e.info.op == opAnd {
+			return "1=1", nil, nil
+		}
+		return "1=0", nil, nil
+	}
+	var blocks []string
+	var args []interface{}
+	for _, sub := range e.exprs {
+		q, subArgs, err := sub.toSql()
+		if err != nil {
+			return "", nil, err
+		}
+		if len(e.exprs) > 1 && needParens(sub) {
+			q = fmt.Sprintf("(%s)", q)
+		}
+		blocks = append(blocks, q)
+		args = append(args, subArgs...)
+	}
+	return strings.Join(blocks, fmt.Sprintf(" %s ", e.info.op)), args, nil
+}
+
+type notExpr struct {
+	expr
+	inner isExpr
+}
+
+func (e *notExpr) toSql() (string, []interface{}, error) {
+	q, args, err := e.inner.toSql()
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("NOT (%s)", q), args, nil
+}
+
+// rawExpr is a piece of raw sql with its args
+type rawExpr struct {
+	expr
+	sql  string
+	args []interface{}
+}
+
+func (e *rawExpr) toSql() (string, []interface{}, error) {
+	return e.sql, e.args, nil
+}
+
+// needParens tells if the expression must be grouped when it is combined
+func needParens(e isExpr) bool {
+	switch e := e.(type) {
+	case *logicExpr:
+		return len(e.exprs) > 1
+	case *rawExpr:
+		return true
+	}
+	return false
+}
+
+// And combines the expressions with `AND`, nil expressions are skipped
+func And(exprs ...isExpr) isExpr {
+	return newLogicExpr(opAnd, exprs)
+}
+
+// Or combines the expressions with `OR`, nil expressions are skipped
+func Or(exprs ...isExpr) isExpr {
+	return newLogicExpr(opOr, exprs)
+}
+
+func newLogicExpr(op string, exprs []isExpr) isExpr {
+	e := &logicExpr{expr: expr{info: exprInfo{op: op}}}
+	for _, sub := range exprs {
+		if sub == nil {
+			continue
+		}
+		// flatten `(a AND b) AND c` to `a AND b AND c`
+		if same, ok := sub.(*logicExpr); ok && same.info.op == op {
+			e.exprs = append(e.exprs, same.exprs...)
+			continue
+		}
+		e.exprs = append(e.exprs, sub)
+	}
+	e.info.v = e.exprs
+	return e
+}
+
+// Not negates the expression
+func Not(e isExpr) isExpr {
+	return &notExpr{expr: expr{info: exprInfo{op: opNot, v: e}}, inner: e}
+}
+
+// Raw makes an expression of raw sql, so it can be combined with others
+//
+// Example:
+//
+//	And(book.Tag.Eq(99), Raw("deleted = ?", false))
+func Raw(sql string, args ...interface{}) isExpr {
+	return &rawExpr{expr: expr{info: exprInfo{op: opRaw}}, sql: sql, args: args}
+}
+
+// toExpr converts a where condition to an expression,
+// the condition may be raw sql with args or an expression
+func toExpr(cond interface{}, args []interface{}) (isExpr, error) {
+	switch c := cond.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		c = strings.Trim(c, " ")
+		if c == "" {
+			return nil, nil
+		}
+		return Raw(c, args...), nil
+	case isExpr:
+		if len(args) > 0 {
+			return nil, errors.New("args are only for raw sql conditions")
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("invalid where condition:%+v", cond)
+}
+
+// combineWhere joins the condition to the where expression with `op`,
+// the where expression may be nil if no condition is set yet
+func combineWhere(op string, where isExpr, cond interface{}, args []interface{}) (isExpr, error) {
+	e, err := toExpr(cond, args)
+	if err != nil {
+		return where, err
+	}
+	if where == nil {
+		return e, nil
+	}
+	if e == nil {
+		return where, nil
+	}
+	if op == opOr {
+		return Or(where, e), nil
+	}
+	return And(where, e), nil
+}
+
+// whereSql compiles the expression to a `WHERE` clause
+func whereSql(where isExpr) (string, []interface{}, error) {
+	if where == nil {
+		return "", nil, nil
+	}
+	q, args, err := where.toSql()
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("WHERE %s", q), args, nil
+}
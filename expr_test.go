@@ -0,0 +1,99 @@
+package om
+
+import (
+	"reflect"
+	"testing"
+)
+
+type tBookFields struct {
+	Name     String
+	Tag      Integer
+	AuthorID Integer
+}
+
+var bookFields = tBookFields{
+	Name:     String{Field: Field{Column: "name"}},
+	Tag:      Integer{Field: Field{Column: "tag"}},
+	AuthorID: Integer{Field: Field{Column: "author_id"}},
+}
+
+func TestExpr_toSql(t *testing.T) {
+	b := bookFields
+	cases := []struct {
+		e    isExpr
+		sql  string
+		args []interface{}
+	}{
+		{b.Name.Eq("Python"), "name = ?", []interface{}{"Python"}},
+		{b.Name.Eq(nil), "name IS NULL", nil},
+		{b.Tag.Ne(nil), "tag IS NOT NULL", nil},
+		{b.Tag.Ge(1), "tag >= ?", []interface{}{1}},
+		{b.Tag.In([]int{1, 2}), "tag IN ?", []interface{}{[]int{1, 2}}},
+		{b.Tag.In([]int{}), "1=0", nil},
+		{b.Tag.Between(1, 9), "tag BETWEEN ? AND ?", []interface{}{1, 9}},
+		{b.AuthorID.Eq(&b.Tag), "author_id = tag", nil},
+		{
+			And(b.Tag.Gt(1), Or(b.Name.Like("Go%"), b.Name.IsNull())),
+			"tag > ? AND (name LIKE ? OR name IS NULL)",
+			[]interface{}{1, "Go%"},
+		},
+		{
+			Not(And(b.Tag.Lt(3), Raw("deleted = ?", false))),
+			"NOT (tag < ? AND (deleted = ?))",
+			[]interface{}{3, false},
+		},
+		{And(nil, b.Tag.Le(3)), "tag <= ?", []interface{}{3}},
+	}
+	for _, c := range cases {
+		q, args, err := c.e.toSql()
+		if err != nil {
+			t.Errorf("err:%v", err)
+		}
+		if q != c.sql {
+			t.Errorf("expect %s, got:%s", c.sql, q)
+		}
+		if !reflect.DeepEqual(args, c.args) {
+			t.Errorf("expect args %v, got:%v", c.args, args)
+		}
+	}
+
+	// IN expects a slice
+	_, _, err := b.Tag.In(1).toSql()
+	if err == nil {
+		t.Error("expect err on none slice IN")
+	}
+}
+
+func TestCombineWhere(t *testing.T) {
+	var where isExpr
+	var err error
+	steps := []struct {
+		op   string
+		cond interface{}
+		args []interface{}
+	}{
+		{opAnd, "tag = ?", []interface{}{99}},
+		{opAnd, bookFields.Name.Like("Go%"), nil},
+		{opOr, "deleted = ?", []interface{}{true}},
+		{opAnd, "", nil},
+		{opAnd, "author_id = ?", []interface{}{1}},
+	}
+	for _, step := range steps {
+		where, err = combineWhere(step.op, where, step.cond, step.args)
+		if err != nil {
+			t.Errorf("err:%v", err)
+		}
+	}
+	q, args, err := where.toSql()
+	if err != nil {
+		t.Errorf("err:%v", err)
+	}
+	expect := "(((tag = ?) AND name LIKE ?) OR (deleted = ?)) AND (author_id = ?)"
+	if q != expect {
+		t.Errorf("expect %s, got:%s", expect, q)
+	}
+	expectArgs := []interface{}{99, "Go%", true, 1}
+	if !reflect.DeepEqual(args, expectArgs) {
+		t.Errorf("expect args %v, got:%v", expectArgs, args)
+	}
+}
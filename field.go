@@ -6,45 +6,97 @@ type Table struct {
 type isTable interface {
 }
 
-type exprInfo struct {
-	f *isField
-	v interface{}
-	op String
+type isField interface {
+	FieldInfo() *Field
 }
 
-func (expr *exprInfo) exprInfo() *exprInfo  {
-	return expr
+type Field struct {
+	Column string
+	Null bool
+	IsPK bool
 }
 
-type isExpr interface {
-	exprInfo() *exprInfo
+func (f *Field) newExpr(op string, v interface{}) isExpr {
+	return &expr{info:exprInfo{f:f, v:v, op:op}}
 }
 
-type eqExpr struct {
-	exprInfo
+// Eq makes `col = ?`, `col IS NULL` if v is nil,
+// v can be another field to compare columns
+func (f *Field) Eq(v interface{}) isEqExpr {
+	return &eqExpr{expr:expr{info:exprInfo{f:f, v:v, op:opEq}}}
 }
 
-func (eq *eqExpr) eqExpr()  *eqExpr {
-	return eq
+// Ne makes `col <> ?`, `col IS NOT NULL` if v is nil
+func (f *Field) Ne(v interface{}) isExpr {
+	if v == nil {
+		return f.IsNotNull()
+	}
+	return f.newExpr(opNe, v)
 }
 
-type isEqExpr interface {
-	isExpr
-	eqExpr() *eqExpr
+// Gt makes `col > ?`
+func (f *Field) Gt(v interface{}) isExpr {
+	return f.newExpr(opGt, v)
 }
 
-type isField interface {
-	FieldInfo() *Field
+// Ge makes `col >= ?`
+func (f *Field) Ge(v interface{}) isExpr {
+	return f.newExpr(opGe, v)
 }
 
-type Field struct {
-	Column string
-	Null bool
-	IsPK bool
+// Lt makes `col < ?`
+func (f *Field) Lt(v interface{}) isExpr {
+	return f.newExpr(opLt, v)
 }
 
-func (f *Field) Eq(v interface{}) isEqExpr {
-	return &eqExpr{exprInfo:exprInfo{f:f, v:v}}
+// Le makes `col <= ?`
+func (f *Field) Le(v interface{}) isExpr {
+	return f.newExpr(opLe, v)
+}
+
+// In makes `col IN (?,?,...)`, vs must be a slice
+func (f *Field) In(vs interface{}) isExpr {
+	return f.newExpr(opIn, vs)
+}
+
+// NotIn makes `col NOT IN (?,?,...)`, vs must be a slice
+func (f *Field) NotIn(vs interface{}) isExpr {
+	return f.newExpr(opNotIn, vs)
+}
+
+// Like makes `col LIKE ?`
+func (f *Field) Like(pattern string) isExpr {
+	return f.newExpr(opLike, pattern)
+}
+
+// NotLike makes `col NOT LIKE ?`
+func (f *Field) NotLike(pattern string) isExpr {
+	return f.newExpr(opNotLike, pattern)
+}
+
+// Between makes `col BETWEEN ? AND ?`
+func (f *Field) Between(begin interface{}, end interface{}) isExpr {
+	return f.newExpr(opBetween, [2]interface{}{begin, end})
+}
+
+// IsNull makes `col IS NULL`
+func (f *Field) IsNull() isExpr {
+	return f.newExpr(opIsNull, nil)
+}
+
+// IsNotNull makes `col IS NOT NULL`
+func (f *Field) IsNotNull() isExpr {
+	return f.newExpr(opIsNotNull, nil)
+}
+
+// Asc orders by the column ascending
+func (f *Field) Asc() *Order {
+	return Asc(f.Column)
+}
+
+// Desc orders by the column descending
+func (f *Field) Desc() *Order {
+	return Desc(f.Column)
 }
 
 func (f *Field) FieldInfo() *Field {
@@ -78,5 +130,5 @@ func (f *ForeignKey) FieldInfo() *Field {
 }
 
 func (f *ForeignKey) Eq(v interface{}) isEqExpr {
-	return nil
+	return &eqExpr{expr:expr{info:exprInfo{f:f, v:v, op:opEq}}}
 }
@@ -0,0 +1,72 @@
+package om
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// QueryEvent describes a query to hooks
+type QueryEvent struct {
+	// Op is the kind of the statement such as `SELECT` and `INSERT`
+	Op string
+	// Table is the table queried by `Tables`, empty for raw queries
+	Table    string
+	SQL      string
+	ArgCount int
+	// Duration and RowsAffected are set only for `After`,
+	// RowsAffected is the number of rows affected or returned, -1 if unknown
+	Duration     time.Duration
+	RowsAffected int64
+}
+
+// QueryHook instruments queries for metrics and tracing,
+// the context returned by `Before` is used to run the query and passed to `After`,
+// so a span started in `Before` can be ended in `After`
+//
+// Example:
+//
+//	type metricsHook struct{}
+//
+//	func (h metricsHook) Before(ctx context.Context, e QueryEvent) context.Context {
+//		return ctx
+//	}
+//
+//	func (h metricsHook) After(ctx context.Context, e QueryEvent, err error) {
+//		queryDuration.WithLabelValues(e.Op, e.Table).Observe(e.Duration.Seconds())
+//	}
+//
+//	db := om.NewDB(sqlxDB, om.WithHook(metricsHook{}))
+type QueryHook interface {
+	Before(ctx context.Context, e QueryEvent) context.Context
+	After(ctx context.Context, e QueryEvent, err error)
+}
+
+// WithHook registers hooks invoked around every query in order
+func WithHook(hooks ...QueryHook) Option {
+	return func(m *DB) {
+		m.dbx.hooks = append(m.dbx.hooks, hooks...)
+	}
+}
+
+type tableKey struct{}
+
+// withTable tags the context with the table queried
+func withTable(ctx context.Context, table string) context.Context {
+	return context.WithValue(ctx, tableKey{}, table)
+}
+
+func tableOf(ctx context.Context) string {
+	table, _ := ctx.Value(tableKey{}).(string)
+	return table
+}
+
+// stmtOp returns the first keyword of the query in upper case
+func stmtOp(query string) string {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	end := strings.IndexAny(query, " \t\r\n(")
+	if end < 0 {
+		end = len(query)
+	}
+	return strings.ToUpper(query[:end])
+}
@@ -0,0 +1,19 @@
+package om
+
+import (
+	"testing"
+)
+
+func TestStmtOp(t *testing.T) {
+	cases := map[string]string{
+		"SELECT * FROM t":              "SELECT",
+		"  insert INTO t(a) VALUES(?)": "INSERT",
+		"(SELECT 1) UNION (SELECT 2)":  "SELECT",
+		"SAVEPOINT":                    "SAVEPOINT",
+	}
+	for q, expect := range cases {
+		if got := stmtOp(q); got != expect {
+			t.Errorf("%s: expect %s, got:%s", q, expect, got)
+		}
+	}
+}
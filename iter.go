@@ -0,0 +1,112 @@
+package om
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+var _ Iterator = &rowsIterator{}
+
+// rowsIterator is an `Iterator` backed by `sqlx.Rows`
+type rowsIterator struct {
+	rows *sqlx.Rows
+	err  error
+	// columns of the rows, loaded on the first scan
+	cols []string
+}
+
+func (it *rowsIterator) Next() bool {
+	if it.err != nil || it.rows == nil {
+		return false
+	}
+	if it.rows.Next() {
+		return true
+	}
+	it.err = it.rows.Err()
+	// release the connection as soon as rows are exhausted
+	it.Close()
+	return false
+}
+
+func (it *rowsIterator) Get(dest interface{}) bool {
+	if it.err != nil {
+		return false
+	}
+	if it.rows == nil {
+		it.err = errors.New("iterator is closed")
+		return false
+	}
+	switch d := dest.(type) {
+	case map[string]interface{}:
+		it.err = it.rows.MapScan(d)
+	case *map[string]interface{}:
+		if *d == nil {
+			*d = map[string]interface{}{}
+		}
+		it.err = it.rows.MapScan(*d)
+	default:
+		v := reflect.ValueOf(dest)
+		if v.Kind() == reflect.Ptr && reflect.Indirect(v).Kind() == reflect.Struct &&
+			!isScannable(v.Type().Elem()) {
+			it.err = it.scanStruct(reflect.Indirect(v))
+		} else {
+			it.err = it.rows.Scan(dest)
+		}
+	}
+	return it.err == nil
+}
+
+// scanStruct scans the row to fields of the struct by column names,
+// columns without fields are dropped, so `SELECT *` works with any model
+func (it *rowsIterator) scanStruct(v reflect.Value) error {
+	if it.cols == nil {
+		cols, err := it.rows.Columns()
+		if err != nil {
+			return err
+		}
+		it.cols = cols
+	}
+	traversals := modelsMapper.TraversalsByName(v.Type(), it.cols)
+	values := make([]interface{}, len(it.cols))
+	for i, traversal := range traversals {
+		if len(traversal) == 0 {
+			values[i] = new(interface{})
+			continue
+		}
+		values[i] = reflectx.FieldByIndexes(v, traversal).Addr().Interface()
+	}
+	return it.rows.Scan(values...)
+}
+
+func (it *rowsIterator) Close() error {
+	if it.rows == nil {
+		return nil
+	}
+	err := it.rows.Close()
+	it.rows = nil
+	return err
+}
+
+func (it *rowsIterator) Err() error {
+	return it.err
+}
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// isScannable tells if the struct is scanned as a single value,
+// such as `sql.NullString` or `time.Time` without exported fields
+func isScannable(tp reflect.Type) bool {
+	if reflect.PtrTo(tp).Implements(scannerType) {
+		return true
+	}
+	for i := 0; i < tp.NumField(); i++ {
+		if tp.Field(i).PkgPath == "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package om
+
+import (
+	"strings"
+)
+
+const (
+	nullsDefault = iota
+	nullsFirst
+	nullsLast
+)
+
+// Order is a term of ORDER BY, it is a column or an expression with args
+//
+// Example:
+//
+//	s.OrderBy(Desc("priority").NullsLast(), Asc("id"))
+//	s.OrderBy(Asc("FIELD(status, ?, ?)", "open", "closed"))
+type Order struct {
+	expr  string
+	args  []interface{}
+	desc  bool
+	nulls int
+}
+
+// Asc orders by the expression ascending
+func Asc(expr string, args ...interface{}) *Order {
+	return &Order{expr: expr, args: args}
+}
+
+// Desc orders by the expression descending
+func Desc(expr string, args ...interface{}) *Order {
+	return &Order{expr: expr, args: args, desc: true}
+}
+
+// NullsFirst puts NULL values before others
+func (o *Order) NullsFirst() *Order {
+	o.nulls = nullsFirst
+	return o
+}
+
+// NullsLast puts NULL values after others
+func (o *Order) NullsLast() *Order {
+	o.nulls = nullsLast
+	return o
+}
+
+// parseOrder parses a term like `created_at DESC NULLS LAST`,
+// the direction is ASC if it's not given
+func parseOrder(term string) *Order {
+	o := &Order{}
+	rest := strings.TrimSpace(term)
+	cut := func(suffix string) bool {
+		if !strings.HasSuffix(strings.ToUpper(rest), suffix) {
+			return false
+		}
+		rest = strings.TrimSpace(rest[:len(rest)-len(suffix)])
+		return true
+	}
+	if cut(" NULLS FIRST") {
+		o.nulls = nullsFirst
+	} else if cut(" NULLS LAST") {
+		o.nulls = nullsLast
+	}
+	if cut(" DESC") {
+		o.desc = true
+	} else {
+		cut(" ASC")
+	}
+	o.expr = rest
+	return o
+}
+
+// toSql compiles the term, NULLS FIRST/LAST is emulated by
+// ordering on `expr IS NULL` firstly if the dialect can't support it
+func (o *Order) toSql(d Dialect) (string, []interface{}) {
+	dir := "ASC"
+	if o.desc {
+		dir = "DESC"
+	}
+	term := strings.Join([]string{o.expr, dir}, " ")
+	if o.nulls == nullsDefault {
+		return term, o.args
+	}
+	if d.SupportsNullsOrder() {
+		nulls := "NULLS FIRST"
+		if o.nulls == nullsLast {
+			nulls = "NULLS LAST"
+		}
+		return strings.Join([]string{term, nulls}, " "), o.args
+	}
+	// `expr IS NULL` is 1 for NULL values, so DESC puts them first
+	nullsDir := "ASC"
+	if o.nulls == nullsFirst {
+		nullsDir = "DESC"
+	}
+	var args []interface{}
+	args = append(args, o.args...)
+	args = append(args, o.args...)
+	return strings.Join([]string{o.expr, "IS NULL", nullsDir + ",", term}, " "), args
+}
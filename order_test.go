@@ -0,0 +1,43 @@
+package om
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOrder(t *testing.T) {
+	cases := []struct {
+		term  string
+		expr  string
+		desc  bool
+		nulls int
+	}{
+		{"id", "id", false, nullsDefault},
+		{"created_at desc", "created_at", true, nullsDefault},
+		{"b.name ASC", "b.name", false, nullsDefault},
+		{"priority DESC NULLS LAST", "priority", true, nullsLast},
+		{"LENGTH(name) nulls first", "LENGTH(name)", false, nullsFirst},
+	}
+	for _, c := range cases {
+		o := parseOrder(c.term)
+		if o.expr != c.expr || o.desc != c.desc || o.nulls != c.nulls {
+			t.Errorf("%s: expect %s/%v/%d, got:%+v", c.term, c.expr, c.desc, c.nulls, o)
+		}
+	}
+}
+
+func TestOrder_toSql(t *testing.T) {
+	q, args := Desc("priority").NullsLast().toSql(Postgres)
+	if q != "priority DESC NULLS LAST" || args != nil {
+		t.Errorf("got:%s, %v", q, args)
+	}
+	// mysql orders on `IS NULL` firstly
+	q, args = Asc("FIELD(status, ?)", "open").NullsFirst().toSql(MySQL)
+	expect := "FIELD(status, ?) IS NULL DESC, FIELD(status, ?) ASC"
+	if q != expect {
+		t.Errorf("expect %s, got:%s", expect, q)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"open", "open"}) {
+		t.Errorf("expect args repeated, got:%v", args)
+	}
+}
@@ -1,6 +1,8 @@
 package om
 
 import (
+	"context"
+	"time"
 	"github.com/jmoiron/sqlx"
 	"github.com/jmoiron/sqlx/reflectx"
 	"strings"
@@ -12,6 +14,13 @@ import (
 
 const (
 	tag = "db"
+	// pkOption marks the primary key column, e.g. `db:"id,pk"`
+	pkOption = "pk"
+	// defaultPK is the primary key column of tables without any `pk` marked
+	defaultPK = "id"
+	// secretOption marks a sensitive column whose args are redacted in logs,
+	// e.g. `db:"password,secret"`
+	secretOption = "secret"
 )
 
 var (
@@ -25,8 +34,13 @@ type Manager struct {
 	tp         reflect.Type
 	model      isModel
 	colInfoMap map[string]*reflectx.FieldInfo
+	// cols are the columns in struct field order
+	cols       []string
 	fieldMap map[string]reflect.Value
 	tpMap      *reflectx.StructMap
+	// pk column name, the column marked as pk, or `id`, or the integer column of `Identity()`,
+	// empty if none of them is mapped
+	pk         string
 }
 
 func newManager(model isModel) (*Manager, error)  {
@@ -34,23 +48,41 @@ func newManager(model isModel) (*Manager, error)  {
 	tp := v.Type()
 	tpMap := modelsMapper.TypeMap(tp)
 	var colsMap = map[string] *reflectx.FieldInfo {}
-	for name, info := range tpMap.Names {
-		_, ok := info.Field.Tag.Lookup(tag)
-		if ok {
-			colsMap[name] = info
+	var cols []string
+	var pk string
+	for _, info := range taggedFields(tpMap) {
+		name := info.Path
+		colsMap[name] = info
+		cols = append(cols, name)
+		if _, isPK := info.Options[pkOption]; isPK {
+			pk = name
+		}
+	}
+	if pk == "" {
+		if _, ok := colsMap[defaultPK]; ok {
+			pk = defaultPK
+		} else if holder, ok := model.(idHolder); ok {
+			// a natural key like a name is not generated, only integers are taken
+			colName, _ := holder.Identity()
+			if info := colsMap[colName]; info != nil && isInteger(info.Field.Type) {
+				pk = colName
+			}
 		}
 	}
 	m := &Manager{
 		model:model,
 		colInfoMap:colsMap,
+		cols:cols,
 		tpMap:tpMap,
 		fieldMap:modelsMapper.FieldMap(v),
 		v:v,
 		tp:tp,
+		pk:pk,
 	}
 	return m, nil
 }
 
+
 func (m *Manager) ColsMap() map[string]interface{} {
 	var colsMap = map[string] interface{}{}
 	for col, _ := range m.colInfoMap {
@@ -59,12 +91,74 @@ func (m *Manager) ColsMap() map[string]interface{} {
 	return colsMap
 }
 
-// Bind try to set model status as bind
+func isInteger(tp reflect.Type) bool {
+	switch tp.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// zeroPK reports whether the model has a pk column of the zero value
+func (m *Manager) zeroPK() bool {
+	if m.pk == "" {
+		return false
+	}
+	pkValue := m.fieldMap[m.pk]
+	return reflect.DeepEqual(pkValue.Interface(), reflect.Zero(pkValue.Type()).Interface())
+}
+
+// insertColsMap returns columns to be inserted in struct field order,
+// a zero pk is left out so the database generates it
+func (m *Manager) insertColsMap() ([]string, map[string]interface{}) {
+	colsMap := m.ColsMap()
+	if m.zeroPK() {
+		delete(colsMap, m.pk)
+	}
+	cols := make([]string, 0, len(colsMap))
+	for _, col := range m.cols {
+		if _, ok := colsMap[col]; ok {
+			cols = append(cols, col)
+		}
+	}
+	return cols, colsMap
+}
+
+// dirtyCols returns the columns of the dirty fields in struct field order,
+// dirty fields are keyed by the names of the top level fields,
+// so an embedded struct is dirty with all its columns,
+// columns of fields the tracker doesn't track are always written
+func (m *Manager) dirtyCols(dirty map[string]interface{}, tracker isDirtyTracker) []string {
+	cols := []string{}
+	for _, col := range m.cols {
+		name := m.tp.Field(m.colInfoMap[col].Index[0]).Name
+		if _, ok := dirty[name]; ok || !tracker.tracks(name) {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+// Bind try to set model status as bind,
+// the inserted id is set to the pk field of the model
 func (m *Manager)Bind(id int64) {
-	// todo: bind id
+	if m.pk == "" {
+		return
+	}
+	f := m.fieldMap[m.pk]
+	if !f.CanSet() {
+		return
+	}
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f.SetInt(id)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f.SetUint(uint64(id))
+	}
 }
 
-// getColumns returns mapping column names of the model `m`
+// getColumns returns mapping column names of the model `m` in struct field order
 func getColumns(tOrModel interface{}) (cols []string) {
 	var tp reflect.Type
 	var ok bool
@@ -74,15 +168,32 @@ func getColumns(tOrModel interface{}) (cols []string) {
 		tp = v.Type()
 	}
 	tpMap := modelsMapper.TypeMap(tp)
-	for name, info := range tpMap.Names {
-		_, ok := info.Field.Tag.Lookup(tag)
-		if ok {
-			cols = append(cols, name)
-		}
+	for _, info := range taggedFields(tpMap) {
+		cols = append(cols, info.Path)
 	}
 	return cols
 }
 
+// taggedFields returns the fields tagged with columns in struct declaration order,
+// fields of embedded structs shadowed by outer ones are left out
+func taggedFields(tpMap *reflectx.StructMap) []*reflectx.FieldInfo {
+	var fields []*reflectx.FieldInfo
+	var walk func(fi *reflectx.FieldInfo)
+	walk = func(fi *reflectx.FieldInfo) {
+		for _, child := range fi.Children {
+			if child == nil {
+				continue
+			}
+			if _, ok := child.Field.Tag.Lookup(tag); ok && tpMap.Paths[child.Path] == child {
+				fields = append(fields, child)
+			}
+			walk(child)
+		}
+	}
+	walk(tpMap.Tree)
+	return fields
+}
+
 func extractModelType(dest interface{}) (tp reflect.Type, err error) {
 	v := reflect.Indirect(reflect.ValueOf(dest))
 	if v.Kind() != reflect.Slice {
@@ -116,10 +227,30 @@ func (m *M) isModel() bool {
 	return true
 }
 
+// Iterator streams rows of a query
+//
+// Example:
+//	it := db.Tb("book").Select().Iter()
+//	defer it.Close()
+//	for it.Next() {
+//		var book Book
+//		if !it.Get(&book) {
+//			break
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
 type Iterator interface {
+	// Next prepares the next row, false if no more rows or any error
 	Next() bool
+	// Get scans the current row to dest, which is a pointer to a struct,
+	// a `map[string]interface{}` or pointers to scalars
 	Get(dest interface{}) bool
+	// Close releases the connection, it's safe to close more than once
 	Close() error
+	// Err returns the error happened in the iteration
+	Err() error
 }
 
 type Donner interface {
@@ -173,15 +304,26 @@ func(qr *queryResult) All(models interface{}) error {
 	if qr.err != nil {
 		return qr.err
 	}
-	qr.err = qr.db.dbx.Select(models, qr.query, qr.args...)
+	qr.err = qr.db.dbx.Select(qr.db.context(), models, qr.query, qr.args...)
 	return qr.err
 }
-func(qr *queryResult) Iter(it *Iterator) error {return nil}
+func(qr *queryResult) Iter(it *Iterator) error {
+	if qr.err != nil {
+		return qr.err
+	}
+	rows, err := qr.db.dbx.Queryx(qr.db.context(), qr.query, qr.args...)
+	qr.err = err
+	if qr.err != nil {
+		return qr.err
+	}
+	*it = &rowsIterator{rows:rows}
+	return nil
+}
 func(qr *queryResult) SliceMap(dest *[]map[string]interface{}) error {
 	if qr.err != nil {
 		return qr.err
 	}
-	rows, err := qr.db.dbx.Queryx(qr.query, qr.args...)
+	rows, err := qr.db.dbx.Queryx(qr.db.context(), qr.query, qr.args...)
 	qr.err = err
 	if qr.err != nil {
 		return qr.err
@@ -203,18 +345,50 @@ func (qr *queryResult)Get(m isModel) error  {
 	if qr.err != nil {
 		return qr.err
 	}
-	qr.err = qr.db.dbx.Get(m, qr.query, qr.args...)
+	qr.err = qr.db.dbx.Get(qr.db.context(), m, qr.query, qr.args...)
 	return qr.err
 }
 
+// SQLLogger logs queries after they are done,
+// spec is the kind of the query such as `[Exec]`
 type SQLLogger interface {
-	Debug(spec string, query string, args []interface{})
-	Error(spec string, err error, query string, args []interface{})
+	Debug(spec string, query string, args []interface{}, elapsed time.Duration)
+	Error(spec string, err error, query string, args []interface{}, elapsed time.Duration)
+	// Warn logs slow queries and retries of transactions, msg is the query
+	// interpolated with its args for slow queries, rows is the number of rows
+	// affected or returned, -1 if unknown, caller is the `file:line` calling into the package
+	Warn(spec string, msg string, rows int64, caller string, elapsed time.Duration)
+}
+
+// nopLogger is the silent default `SQLLogger`
+type nopLogger struct {
+}
+
+func (log nopLogger) Debug(spec string, query string, args []interface{}, elapsed time.Duration) {
+}
+
+func (log nopLogger) Error(spec string, err error, query string, args []interface{}, elapsed time.Duration) {
+}
+
+func (log nopLogger) Warn(spec string, msg string, rows int64, caller string, elapsed time.Duration) {
+}
+
+// sqlxConn runs queries, it's a `*sqlx.DB` or a `*sqlx.Tx`
+type sqlxConn interface {
+	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 }
 
 type wrappedDB struct {
-	DB     *sqlx.DB
-	logger SQLLogger
+	DB      sqlxConn
+	logger  SQLLogger
+	dialect Dialect
+	// slowThreshold is the duration of slow queries, 0 to disable
+	slowThreshold time.Duration
+	redaction     *Redaction
+	hooks         []QueryHook
 }
 
 //func (w *wrappedDB) Query(query string, args...interface{}) (*sql.Rows, error) {
@@ -226,76 +400,228 @@ type wrappedDB struct {
 //	return w.DB.Query(query, args...)
 //}
 
-func (w *wrappedDB) Queryx(query string, args...interface{}) (rows *sqlx.Rows, err error) {
-	err = parseINSpec(&query, &args)
-	if err != nil {
-		return nil, err
-	}
-	w.logger.Debug("[Queryx]", query, args)
-	rows, err = w.DB.Queryx(query, args...)
-	if err != nil {
-		w.logger.Error("[Queryx]", err, query, args)
-	}
+func (w *wrappedDB) Queryx(ctx context.Context, query string, args...interface{}) (rows *sqlx.Rows, err error) {
+	err = w.run(ctx, "[Queryx]", query, args, func(ctx context.Context, bound string, args []interface{}) error {
+		rows, err = w.DB.QueryxContext(ctx, bound, args...)
+		return err
+	}, func() int64 {
+		// rows are streamed, so the count is unknown
+		return -1
+	})
 	return rows, err
 }
 
-func (w *wrappedDB) Get(dest interface{}, query string, args ...interface{}) (err error) {
-	err = parseINSpec(&query, &args)
-	if err != nil {
+func (w *wrappedDB) Get(ctx context.Context, dest interface{}, query string, args ...interface{}) (err error) {
+	return w.run(ctx, "[Get]", query, args, func(ctx context.Context, bound string, args []interface{}) error {
+		return w.DB.GetContext(ctx, dest, bound, args...)
+	}, func() int64 {
+		return 1
+	})
+}
+
+func (w *wrappedDB) Select(ctx context.Context, dest interface{}, query string, args ...interface{}) (err error) {
+	return w.run(ctx, "[Select]", query, args, func(ctx context.Context, bound string, args []interface{}) error {
+		return w.DB.SelectContext(ctx, dest, bound, args...)
+	}, func() int64 {
+		return int64(reflect.Indirect(reflect.ValueOf(dest)).Len())
+	})
+}
+
+func (w *wrappedDB) Exec(ctx context.Context, query string, args...interface{}) (re sql.Result, err error) {
+	err = w.run(ctx, "[Exec]", query, args, func(ctx context.Context, bound string, args []interface{}) error {
+		re, err = w.DB.ExecContext(ctx, bound, args...)
 		return err
-	}
-	w.logger.Debug("[Get]", query, args)
-	err = w.DB.Get(dest, query, args...)
-	if err != nil {
-		w.logger.Error("[Get]", err, query, args)
-	}
-	return err
+	}, func() int64 {
+		n, err := re.RowsAffected()
+		if err != nil {
+			return -1
+		}
+		return n
+	})
+	return re, err
 }
 
-func (w *wrappedDB) Select(dest interface{}, query string, args ...interface{}) (err error) {
-	err = parseINSpec(&query, &args)
+// run expands and binds the query for the dialect then runs it by `do`,
+// the query is logged and passed to hooks, rows is called for the number
+// of rows affected or returned only after the query succeeded
+func (w *wrappedDB) run(ctx context.Context, spec string, query string, args []interface{},
+	do func(ctx context.Context, bound string, args []interface{}) error, rows func() int64) error {
+	err := parseINSpec(&query, &args)
 	if err != nil {
 		return err
 	}
-	w.logger.Debug("[Select]", query, args)
-	err =  w.DB.Select(dest, query, args...)
-	if err != nil {
-		w.logger.Error("[Select]", err, query, args)
+	bound := w.dialect.Rebind(query)
+	event := QueryEvent{
+		Op:stmtOp(query),
+		Table:tableOf(ctx),
+		SQL:bound,
+		ArgCount:len(args),
+		RowsAffected:-1,
+	}
+	// each hook gets back the context it returned
+	hookCtxs := make([]context.Context, len(w.hooks))
+	for i, hook := range w.hooks {
+		ctx = hook.Before(ctx, event)
+		hookCtxs[i] = ctx
+	}
+	begin := time.Now()
+	err = do(ctx, bound, args)
+	elapsed := time.Since(begin)
+	w.logQuery(ctx, spec, query, bound, args, elapsed, err, rows)
+	if len(w.hooks) > 0 {
+		event.Duration = elapsed
+		if err == nil {
+			event.RowsAffected = rows()
+		}
+		for i := len(w.hooks) - 1; i >= 0; i-- {
+			w.hooks[i].After(hookCtxs[i], event, err)
+		}
 	}
 	return err
 }
 
-func (w *wrappedDB) Exec(query string, args...interface{}) (re sql.Result, err error) {
-	err = parseINSpec(&query, &args)
+// logQuery logs the query after it's done, queries taking longer than
+// the slow threshold are logged at WARN with the interpolated sql as well,
+// query is the sql with `?` placeholders and bound is the one sent to the db
+func (w *wrappedDB) logQuery(ctx context.Context, spec string, query string, bound string, args []interface{},
+	elapsed time.Duration, err error, rows func() int64) {
+	args = w.redaction.redact(ctx, query, args)
 	if err != nil {
-		return nil, err
+		w.logger.Error(spec, err, bound, args, elapsed)
+		return
 	}
-	w.logger.Debug("[Exec]", query, args)
-	re, err = w.DB.Exec(query, args...)
-	if err != nil {
-		w.logger.Error("[Exec]", err, query, args)
+	w.logger.Debug(spec, bound, args, elapsed)
+	if w.slowThreshold > 0 && elapsed >= w.slowThreshold {
+		w.logger.Warn(spec, interpolate(query, args), rows(), callerOf(), elapsed)
 	}
-	return re, err
 }
 
 type DB struct {
-	dbx *wrappedDB
+	dbx     *wrappedDB
+	dialect Dialect
+	ctx     context.Context
+	// sqlxDB begins transactions, nil for the db of a `Tx`
+	sqlxDB  *sqlx.DB
+	// audit records changes of tracked models, nil to disable
+	audit   AuditSink
 }
 
 type sqlLogger struct {
 	logger *logrus.Entry
 }
 
-func (log *sqlLogger) Debug(spec string, query string, args []interface{})  {
+// NewLogrusLogger adapts the logrus entry to `SQLLogger`,
+// queries are logged with the query, args and duration as fields
+func NewLogrusLogger(logger *logrus.Entry) SQLLogger {
+	return &sqlLogger{logger:logger}
+}
+
+func (log *sqlLogger) fields(query string, args []interface{}, elapsed time.Duration) *logrus.Entry {
+	return log.logger.WithFields(logrus.Fields{
+		"query":query,
+		"args":args,
+		"duration":elapsed,
+	})
+}
+
+func (log *sqlLogger) Debug(spec string, query string, args []interface{}, elapsed time.Duration)  {
+	log.fields(query, args, elapsed).Debug(spec)
 }
 
-func (log *sqlLogger) Error(spec string, query string, args []interface{})  {
+func (log *sqlLogger) Error(spec string, err error, query string, args []interface{}, elapsed time.Duration)  {
+	log.fields(query, args, elapsed).WithError(err).Error(spec)
 }
 
-// todo: resove SQLLogger inner
-func NewDB(db *sqlx.DB, logger *logrus.Entry) *DB {
-	w := &wrappedDB{DB:db, logger:logger}
-	return &DB{w}
+func (log *sqlLogger) Warn(spec string, msg string, rows int64, caller string, elapsed time.Duration)  {
+	fields := logrus.Fields{
+		"query":msg,
+		"duration":elapsed,
+		"caller":caller,
+	}
+	if rows >= 0 {
+		fields["rows"] = rows
+	}
+	log.logger.WithFields(fields).Warn(spec)
+}
+
+// Option configures the db made by `NewDB`
+type Option func(m *DB)
+
+// WithLogger logs queries by the logger, nothing is logged by default
+//
+// Example:
+//	db := om.NewDB(sqlxDB, om.WithLogger(om.NewLogrusLogger(logrus.WithField("mod", "om"))))
+func WithLogger(logger SQLLogger) Option {
+	return func(m *DB) {
+		m.dbx.logger = logger
+	}
+}
+
+// WithSlowQuery logs queries taking longer than the threshold at WARN,
+// 0 disables it which is the default
+func WithSlowQuery(threshold time.Duration) Option {
+	return func(m *DB) {
+		m.dbx.slowThreshold = threshold
+	}
+}
+
+// WithRedaction hides args of the sensitive columns of the policy in logs,
+// columns tagged `secret` are always hidden in queries built with the model,
+// list them in the policy to hide them in queries by maps or raw sql too
+//
+// Example:
+//	db := om.NewDB(sqlxDB, om.WithRedaction(&om.Redaction{
+//		Columns:[]string{"token", "user.password"},
+//		Patterns:[]*regexp.Regexp{regexp.MustCompile(`(?i)secret|passw`)},
+//	}))
+func WithRedaction(r *Redaction) Option {
+	return func(m *DB) {
+		m.dbx.redaction = r
+	}
+}
+
+// WithDialect uses the dialect instead of the one registered for the driver
+func WithDialect(d Dialect) Option {
+	return func(m *DB) {
+		m.dialect = d
+		m.dbx.dialect = d
+	}
+}
+
+// NewDB wraps the db, the dialect is chosen by the driver name of the db
+// unless `WithDialect` is given
+func NewDB(db *sqlx.DB, opts ...Option) *DB {
+	dialect := dialectOf(db.DriverName())
+	w := &wrappedDB{DB:db, logger:nopLogger{}, dialect:dialect}
+	m := &DB{dbx:w, dialect:dialect, sqlxDB:db}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Dialect returns the sql dialect of the db
+func (m *DB) Dialect() Dialect {
+	return m.dialect
+}
+
+// WithContext returns a shallow copy of the db using the context,
+// queries of the copy are canceled once the context is done
+//
+// Example:
+//	db.WithContext(r.Context()).Tb("book").Select().All(&books)
+func (m *DB) WithContext(ctx context.Context) *DB {
+	cp := *m
+	cp.ctx = ctx
+	return &cp
+}
+
+// context returns the context of the db, `context.Background` by default
+func (m *DB) context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
 }
 
 func (m *DB) Tb(table string, alias ...string) *Tables {
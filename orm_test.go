@@ -19,8 +19,28 @@ func TestGetColumns(t *testing.T)  {
 	}
 	author := Author{Age:99}
 	cols := getColumns(author)
-	if len(cols) != 3 {
-		t.Errorf("expect 3 columns got:%d", len(cols))
+	// columns are in struct field order
+	if !reflect.DeepEqual(cols, []string{"age", "name", "goto"}) {
+		t.Errorf("expect columns [age name goto], got:%v", cols)
+	}
+
+	// a field of an embedded struct shadowed by an outer one is left out
+	type Base struct {
+		Name string `db:"name"`
+		Id   int64  `db:"id"`
+	}
+	type Book struct {
+		M
+		Base
+		Name string `db:"name"`
+		Tag  int    `db:"tag"`
+	}
+	if cols := getColumns(Book{}); !reflect.DeepEqual(cols, []string{"id", "name", "tag"}) {
+		t.Errorf("expect columns [id name tag], got:%v", cols)
+	}
+	manager, _ := newManager(&Book{Base: Base{Name: "inner"}, Name: "outer"})
+	if !reflect.DeepEqual(manager.cols, []string{"id", "name", "tag"}) || manager.ColsMap()["name"] != "outer" {
+		t.Errorf("expect the outer name column, got:%v %v", manager.cols, manager.ColsMap())
 	}
 
 	var authors []Author
@@ -48,3 +68,74 @@ func TestParseSliceIn(t *testing.T) {
 	t.Logf("q:%s, args:%v", q, args)
 }
 
+
+func TestManager_PK(t *testing.T) {
+	type Book struct {
+		M
+		ID int64 `db:"id,pk"`
+		Name string `db:"name"`
+	}
+	book := Book{Name:"Golang"}
+	m, err := newManager(&book)
+	if err != nil {
+		t.Errorf("err:%v", err)
+	}
+	if m.pk != "id" {
+		t.Errorf("expect pk id, got:%s", m.pk)
+	}
+	// zero pk is generated by the database
+	cols, colsMap := m.insertColsMap()
+	if _, ok := colsMap["id"]; ok || !reflect.DeepEqual(cols, []string{"name"}) {
+		t.Error("expect zero pk not to be inserted")
+	}
+	m.Bind(9)
+	if book.ID != 9 {
+		t.Errorf("expect bind id 9, got:%d", book.ID)
+	}
+}
+
+type tIdentityBook struct {
+	M
+	No   int64  `db:"book_no"`
+	Name string `db:"name"`
+}
+
+func (b *tIdentityBook) Identity() (string, interface{}) {
+	return "book_no", b.No
+}
+
+func TestManager_defaultPK(t *testing.T) {
+	// `id` is the pk without any marked
+	type Book struct {
+		M
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	m, _ := newManager(&Book{Name: "Golang"})
+	if m.pk != "id" {
+		t.Errorf("expect pk id, got:%s", m.pk)
+	}
+	if cols, _ := m.insertColsMap(); !reflect.DeepEqual(cols, []string{"name"}) {
+		t.Errorf("expect zero id not to be inserted, got:%v", cols)
+	}
+
+	// or the column of `Identity()`
+	book := &tIdentityBook{Name: "Golang"}
+	m, _ = newManager(book)
+	if m.pk != "book_no" {
+		t.Errorf("expect pk book_no, got:%s", m.pk)
+	}
+	if cols, _ := m.insertColsMap(); !reflect.DeepEqual(cols, []string{"name"}) {
+		t.Errorf("expect zero book_no not to be inserted, got:%v", cols)
+	}
+	m.Bind(9)
+	if book.No != 9 {
+		t.Errorf("expect bind book_no 9, got:%d", book.No)
+	}
+
+	// a natural key of `Identity()` is not a generated pk
+	m, _ = newManager(&tBook{})
+	if m.pk != "" {
+		t.Errorf("expect no pk, got:%s", m.pk)
+	}
+}
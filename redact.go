@@ -0,0 +1,175 @@
+package om
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+const (
+	// redacted replaces args of sensitive columns in logs
+	redacted = "***"
+	// lookback is the length of sql before a placeholder to find its column
+	lookback = 128
+)
+
+var (
+	// insertRe matches `INSERT INTO t(a, b) VALUES`
+	insertRe = regexp.MustCompile(`(?is)^\s*(?:INSERT|REPLACE)\s+(?:INTO\s+)?[^\s(]+\s*\(([^)]*)\)\s*VALUES`)
+	// compareRe matches the column compared with the placeholder at the end,
+	// such as `a.name = `, `name LIKE ` and `name IN (?, `
+	compareRe = regexp.MustCompile(
+		"(?is)([\\w.`\"]+)\\s*(?:=|<>|!=|<=|>=|<|>|\\bNOT\\s+LIKE|\\bLIKE|\\bNOT\\s+IN\\s*\\(|\\bIN\\s*\\()[\\s?,]*$")
+)
+
+// secretCols returns the columns tagged `secret` of the model or its type
+func secretCols(tOrModel interface{}) []string {
+	tp, ok := tOrModel.(reflect.Type)
+	if !ok {
+		tp = reflect.Indirect(reflect.ValueOf(tOrModel)).Type()
+	}
+	tp = reflectx.Deref(tp)
+	if tp.Kind() != reflect.Struct {
+		return nil
+	}
+	var cols []string
+	for _, info := range taggedFields(modelsMapper.TypeMap(tp)) {
+		if _, isSecret := info.Options[secretOption]; isSecret {
+			cols = append(cols, info.Path)
+		}
+	}
+	return cols
+}
+
+type secretsKey struct{}
+
+// withSecrets carries the secret columns of the models of the query
+func withSecrets(ctx context.Context, cols map[string]bool) context.Context {
+	return context.WithValue(ctx, secretsKey{}, cols)
+}
+
+func secretsOf(ctx context.Context) map[string]bool {
+	cols, _ := ctx.Value(secretsKey{}).(map[string]bool)
+	return cols
+}
+
+// Redaction is the policy of sensitive columns,
+// args of them are logged as `***`
+type Redaction struct {
+	// Columns are the names of sensitive columns,
+	// a name like `user.password` is sensitive only on the table queried by `Tables`
+	Columns []string
+	// Patterns match the names of sensitive columns
+	Patterns []*regexp.Regexp
+}
+
+// sensitive tells if the column of the table is sensitive,
+// secrets are the columns tagged `secret` by the models of the query
+func (r *Redaction) sensitive(table string, secrets map[string]bool, col string) bool {
+	if secrets[col] {
+		return true
+	}
+	if r == nil {
+		return false
+	}
+	for _, c := range r.Columns {
+		if strings.EqualFold(c, col) || (table != "" && strings.EqualFold(c, table+"."+col)) {
+			return true
+		}
+	}
+	for _, p := range r.Patterns {
+		if p.MatchString(col) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns the args to log, args of sensitive columns are replaced,
+// the columns of placeholders are guessed from the column list of INSERT
+// and comparisons such as `col = ?`, args are kept if no column is found
+func (r *Redaction) redact(ctx context.Context, query string, args []interface{}) []interface{} {
+	secrets := secretsOf(ctx)
+	if len(args) == 0 || (r == nil && len(secrets) == 0) {
+		return args
+	}
+	table := tableOf(ctx)
+	cols := placeholderCols(query)
+	var logged []interface{}
+	for i, col := range cols {
+		if i >= len(args) {
+			break
+		}
+		if col == "" || !r.sensitive(table, secrets, col) {
+			continue
+		}
+		if logged == nil {
+			logged = make([]interface{}, len(args))
+			copy(logged, args)
+		}
+		logged[i] = redacted
+	}
+	if logged == nil {
+		return args
+	}
+	return logged
+}
+
+// placeholderCols guesses the column of each `?` placeholder of the query,
+// it's empty if the column is unknown
+func placeholderCols(query string) []string {
+	var insertCols []string
+	valuesAt := -1
+	if m := insertRe.FindStringSubmatchIndex(query); m != nil {
+		for _, col := range strings.Split(query[m[2]:m[3]], ",") {
+			insertCols = append(insertCols, normalizeCol(col))
+		}
+		valuesAt = m[1]
+	}
+	var cols []string
+	var quote rune
+	inserted := 0
+	for i, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			col := ""
+			prefix := strings.TrimRight(query[:i], " \t\n")
+			if valuesAt >= 0 && i >= valuesAt {
+				if len(insertCols) > 0 {
+					col = insertCols[inserted%len(insertCols)]
+				}
+				inserted++
+			} else if len(cols) > 0 && strings.HasSuffix(strings.TrimRight(strings.TrimSuffix(prefix, ","), " \t\n"), "?") {
+				// the rest of `IN (?, ?, ?)`
+				col = cols[len(cols)-1]
+			} else {
+				if len(prefix) > lookback {
+					prefix = prefix[len(prefix)-lookback:]
+				}
+				if m := compareRe.FindStringSubmatch(prefix); m != nil {
+					col = normalizeCol(m[1])
+				}
+			}
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+// normalizeCol turns `b.name` or `"name"` to `name`
+func normalizeCol(col string) string {
+	col = strings.TrimSpace(col)
+	if i := strings.LastIndex(col, "."); i >= 0 {
+		col = col[i+1:]
+	}
+	return strings.ToLower(strings.Trim(col, "`\""))
+}
@@ -0,0 +1,74 @@
+package om
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestPlaceholderCols(t *testing.T) {
+	cases := []struct {
+		query  string
+		expect []string
+	}{
+		{"INSERT INTO user(name, `password`) VALUES(?,?),(?,?)",
+			[]string{"name", "password", "name", "password"}},
+		{"UPDATE user SET password=?, name = ? WHERE u.id=?",
+			[]string{"password", "name", "id"}},
+		{"SELECT * FROM user WHERE token IN (?, ?,?) AND note LIKE ? AND ? > 1",
+			[]string{"token", "token", "token", "note", ""}},
+		{"SELECT * FROM user WHERE note = '?' AND \"Token\" <> ?",
+			[]string{"token"}},
+	}
+	for _, c := range cases {
+		got := placeholderCols(c.query)
+		if !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("%s: expect %v, got:%v", c.query, c.expect, got)
+		}
+	}
+}
+
+func TestRedaction_redact(t *testing.T) {
+	// columns tagged `secret` by the models of the query are redacted
+	secrets := secretCols(struct {
+		Name   string `db:"name"`
+		Secret string `db:"om_test_secret,secret"`
+	}{})
+	ctx := withSecrets(context.Background(), map[string]bool{secrets[0]: true})
+	var r *Redaction
+	args := []interface{}{"Python", "pwd"}
+	got := r.redact(ctx, "INSERT INTO t(name, om_test_secret) VALUES(?, ?)", args)
+	expect := []interface{}{"Python", redacted}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expect %v, got:%v", expect, got)
+	}
+	if args[1] != "pwd" {
+		t.Errorf("expect args are kept, got:%v", args)
+	}
+
+	if got := r.redact(context.Background(), "INSERT INTO t(name, om_test_secret) VALUES(?, ?)", args); !reflect.DeepEqual(got, args) {
+		t.Errorf("expect no secrets out of the query, got:%v", got)
+	}
+
+	r = &Redaction{
+		Columns:  []string{"Phone", "user.password"},
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`(?i)token$`)},
+	}
+	got = r.redact(context.Background(), "UPDATE t SET phone=?, api_token=?, name=? WHERE id=?", []interface{}{"1", "2", "3", 4})
+	expect = []interface{}{redacted, redacted, "3", 4}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expect %v, got:%v", expect, got)
+	}
+
+	// qualified columns are sensitive only on their table
+	query := "UPDATE t SET password=? WHERE id=?"
+	got = r.redact(withTable(context.Background(), "user"), query, []interface{}{"pwd", 1})
+	if !reflect.DeepEqual(got, []interface{}{redacted, 1}) {
+		t.Errorf("expect password redacted on user, got:%v", got)
+	}
+	got = r.redact(withTable(context.Background(), "book"), query, []interface{}{"pwd", 1})
+	if !reflect.DeepEqual(got, []interface{}{"pwd", 1}) {
+		t.Errorf("expect password kept on book, got:%v", got)
+	}
+}
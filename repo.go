@@ -26,6 +26,7 @@ type SelectSpec struct {
 	err error
 	repo isRepo
 	joins [] *joinSpec
+	where isExpr
 }
 
 func (s *SelectSpec) On(eqExpr isEqExpr) *SelectSpec {
@@ -39,8 +40,8 @@ func (s *SelectSpec) On(eqExpr isEqExpr) *SelectSpec {
 		s.err = errors.New("on expr should use after join exprs")
 		return s
 	}
-	lastJoin := s.joins[-1]
-	if lastJoin.on == nil {
+	lastJoin := s.joins[len(s.joins) - 1]
+	if lastJoin.on[0] != nil {
 		s.err = errors.New("already set on expr")
 		return s
 	}
@@ -48,18 +49,31 @@ func (s *SelectSpec) On(eqExpr isEqExpr) *SelectSpec {
 	return s
 }
 
+// Where sets the where condition,
+// the condition is raw sql with args or an expression,
+// calling it again joins the conditions with `AND`
+func (s *SelectSpec) Where(where interface{}, args...interface{}) *SelectSpec {
+	cond, err := combineWhere(opAnd, s.where, where, args)
+	if err != nil {
+		s.err = err
+		return s
+	}
+	s.where = cond
+	return s
+}
+
 func (s *SelectSpec) LJ(other isRepo) *SelectSpec {
-	s.joins = append(s.joins, &joinSpec{joinType:LJ, repo:other, on:nil})
+	s.joins = append(s.joins, &joinSpec{joinType:LJ, repo:other})
 	return s
 }
 
 func (s *SelectSpec) RJ(other isRepo) *SelectSpec {
-	s.joins = append(s.joins, &joinSpec{joinType:RJ, repo:other, on:nil})
+	s.joins = append(s.joins, &joinSpec{joinType:RJ, repo:other})
 	return s
 }
 
 func (s *SelectSpec) IJ(other isRepo) *SelectSpec {
-	s.joins = append(s.joins, &joinSpec{joinType:IJ, repo:other, on:nil})
+	s.joins = append(s.joins, &joinSpec{joinType:IJ, repo:other})
 	return s
 }
 
@@ -0,0 +1,90 @@
+package om
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// pkgDir is the directory of the package sources,
+// frames in it are skipped to find the caller of a query
+var pkgDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// callerOf returns the `file:line` of the first frame out of the package,
+// test files of the package are taken as callers
+func callerOf() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		inPkg := filepath.Dir(frame.File) == pkgDir && !strings.HasSuffix(frame.File, "_test.go")
+		if !inPkg && frame.File != "" && !strings.HasPrefix(frame.Function, "runtime.") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// interpolate replaces `?` placeholders of the query with literal args,
+// placeholders in quoted strings are kept, it's only for logging
+func interpolate(query string, args []interface{}) string {
+	var buf strings.Builder
+	var quote rune
+	next := 0
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?' && next < len(args):
+			buf.WriteString(literal(args[next]))
+			next++
+			continue
+		}
+		buf.WriteRune(c)
+	}
+	return buf.String()
+}
+
+// literal formats the arg as a sql literal
+func literal(arg interface{}) string {
+	if valuer, ok := arg.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			return "?"
+		}
+		arg = v
+	}
+	switch v := arg.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return quoteLiteral(v)
+	case []byte:
+		return quoteLiteral(string(v))
+	case time.Time:
+		return quoteLiteral(v.Format("2006-01-02 15:04:05.999999"))
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return fmt.Sprintf("%v", arg)
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
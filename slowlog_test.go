@@ -0,0 +1,38 @@
+package om
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInterpolate(t *testing.T) {
+	at := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	cases := []struct {
+		query  string
+		args   []interface{}
+		expect string
+	}{
+		{"SELECT * FROM t WHERE a = ? AND b = ?", []interface{}{1, "x"},
+			"SELECT * FROM t WHERE a = 1 AND b = 'x'"},
+		{"UPDATE t SET name=? WHERE note = '?'", []interface{}{"O'Neil"},
+			"UPDATE t SET name='O''Neil' WHERE note = '?'"},
+		{"INSERT INTO t(a,b,c,d) VALUES(?,?,?,?)", []interface{}{nil, true, at, sql.NullInt64{}},
+			"INSERT INTO t(a,b,c,d) VALUES(NULL,TRUE,'2018-01-02 03:04:05',NULL)"},
+		{"SELECT ?, ?", []interface{}{1.5}, "SELECT 1.5, ?"},
+	}
+	for _, c := range cases {
+		got := interpolate(c.query, c.args)
+		if got != c.expect {
+			t.Errorf("expect %s, got:%s", c.expect, got)
+		}
+	}
+}
+
+func TestCallerOf(t *testing.T) {
+	caller := callerOf()
+	if !strings.Contains(caller, "slowlog_test.go:") {
+		t.Errorf("expect the test file as caller, got:%s", caller)
+	}
+}
@@ -7,30 +7,46 @@ import (
 	"bytes"
 	"github.com/jmoiron/sqlx"
 	"reflect"
+	"database/sql"
+	"context"
+	"sort"
 )
 
 type DeferWhere struct {
 	tb *Tables
+	// cols are the columns of colsMap in order, sorted if nil
+	cols []string
 	colsMap map[string]interface{}
 	cb func(w *DeferWhere) (int64, error)
 
-	where string
-	args []interface{}
+	where isExpr
 }
 
-// Where method attaches where condition and args
-func (w *DeferWhere) Where(where string, args...interface{}) Donner {
-	w.where = where
-	w.args = args
-	exec := &executor{
-		callback:func() (int64, error){
-			if w.tb.err != nil {
-				return 0, w.tb.err
-			}
-			return w.cb(w)
-		},
+// Where method attaches where condition,
+// the condition is raw sql with args or an expression,
+// calling it again is the same as `AndWhere`
+func (w *DeferWhere) Where(where interface{}, args...interface{}) *DeferWhere {
+	return w.AndWhere(where, args...)
+}
+
+// AndWhere joins the condition to the where conditions with `AND`
+func (w *DeferWhere) AndWhere(where interface{}, args...interface{}) *DeferWhere {
+	cond, err := combineWhere(opAnd, w.where, where, args)
+	if err != nil {
+		w.tb.err = err
 	}
-	return exec
+	w.where = cond
+	return w
+}
+
+// OrWhere joins the condition to the where conditions with `OR`
+func (w *DeferWhere) OrWhere(where interface{}, args...interface{}) *DeferWhere {
+	cond, err := combineWhere(opOr, w.where, where, args)
+	if err != nil {
+		w.tb.err = err
+	}
+	w.where = cond
+	return w
 }
 
 // Done ends up deferring process right now
@@ -46,15 +62,23 @@ type Select struct {
 	tb *Tables
 	cols []string
 
-	where string
-	args []interface{}
+	where isExpr
 
-	orderCols []string
-	// default is asc
-	orderDesc bool
+	groupCols []string
+	having isExpr
 
-	// [0]:begin, [1]end, nil: no limit
-	limit []int
+	orders []*Order
+
+	// count of rows, < 0 means no limit
+	limit int
+	// count of rows to skip
+	offset int
+
+	// keyset pagination, seek rows after(or before) the cursor
+	cursor *Cursor
+	before bool
+	// rows scanned by `All`, to make cursors
+	scanned interface{}
 }
 
 func parseINSpec(pquery *string, pargs *[]interface{}) error {
@@ -72,7 +96,8 @@ func parseINSpec(pquery *string, pargs *[]interface{}) error {
 	for j, arg = range args {
 		sb.WriteString(queryS[j])
 		v := reflect.ValueOf(arg)
-		if v.Kind() == reflect.Slice {
+		// []byte is a single value rather than a set
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
 			sArgs := make([]interface{}, v.Len())
 			for i:= 0; i < v.Len(); i++ {
 				sArgs[i] = v.Index(i).Interface()
@@ -99,75 +124,186 @@ func parseINSpec(pquery *string, pargs *[]interface{}) error {
 }
 
 func NewSelect(tb *Tables, cols...string) *Select {
-	return &Select{tb:tb,cols:cols, err:tb.err}
+	return &Select{tb:tb,cols:cols, err:tb.err, limit:-1}
+}
+
+// Where sets the where condition,
+// the condition is raw sql with args or an expression,
+// calling it again is the same as `AndWhere`
+//
+// Example:
+//	s.Where("tag = ?", 99)
+//	s.Where(And(book.Tag.Eq(99), book.Name.Like("Go%")))
+func(s *Select) Where(where interface{}, args...interface{}) *Select {
+	return s.AndWhere(where, args...)
+}
+
+// AndWhere joins the condition to the where conditions with `AND`
+//
+// Example:
+//	// WHERE (tag = ?) AND (name LIKE ?)
+//	s.Where("tag = ?", 99).AndWhere("name LIKE ?", "Go%")
+func (s *Select) AndWhere(where interface{}, args...interface{}) *Select {
+	cond, err := combineWhere(opAnd, s.where, where, args)
+	if err != nil {
+		s.err = err
+		return s
+	}
+	s.where = cond
+	return s
+}
+
+// OrWhere joins the condition to the where conditions with `OR`,
+// the conditions before are grouped
+//
+// Example:
+//	// WHERE ((tag = ?) AND (name LIKE ?)) OR (deleted = ?)
+//	s.Where("tag = ?", 99).AndWhere("name LIKE ?", "Go%").OrWhere("deleted = ?", true)
+func (s *Select) OrWhere(where interface{}, args...interface{}) *Select {
+	cond, err := combineWhere(opOr, s.where, where, args)
+	if err != nil {
+		s.err = err
+		return s
+	}
+	s.where = cond
+	return s
+}
+
+// GroupBy sets the GROUP BY columns
+func (s *Select) GroupBy(cols...string) *Select {
+	s.groupCols = append(s.groupCols, cols...)
+	return s
 }
 
-func(s *Select) Where(where string, args...interface{}) *Select {
-	if s.where != "" {
-		s.err = errors.New("where alreay set")
+// Having sets the HAVING condition, it is raw sql with args or an expression,
+// calling it again joins the conditions with `AND`
+//
+// Example:
+//	s.GroupBy("author_id").Having("COUNT(*) > ?", 1)
+func (s *Select) Having(having interface{}, args...interface{}) *Select {
+	cond, err := combineWhere(opAnd, s.having, having, args)
+	if err != nil {
+		s.err = err
 		return s
 	}
-	s.where = strings.Trim(where, " ")
-	s.args = args
+	s.having = cond
 	return s
 }
 
+// OrderBy appends ORDER BY terms, a term is a string like
+// `created_at DESC NULLS LAST` or an `*Order` made by `Asc` or `Desc`
+//
+// Example:
+//	s.OrderBy("priority DESC", "id")
+//	s.OrderBy(Desc("priority").NullsLast(), Asc("id"))
+func (s *Select) OrderBy(terms...interface{}) *Select {
+	for _, term := range terms {
+		switch o := term.(type) {
+		case string:
+			s.orders = append(s.orders, parseOrder(o))
+		case *Order:
+			s.orders = append(s.orders, o)
+		default:
+			s.err = fmt.Errorf("invalid order term:%+v", term)
+		}
+	}
+	return s
+}
+
+// OrderAsc appends ascending ORDER BY columns
 func (s *Select) OrderAsc(cols...string) *Select {
-	s.orderCols = cols
-	s.orderDesc = false
+	for _, col := range cols {
+		s.orders = append(s.orders, Asc(col))
+	}
 	return s
 }
 
+// OrderDesc appends descending ORDER BY columns
 func (s *Select) OrderDesc(cols...string) *Select {
-	s.orderDesc = true
-	s.orderCols = cols
+	for _, col := range cols {
+		s.orders = append(s.orders, Desc(col))
+	}
 	return s
 }
 
-func (s *Select) Limit(begin int, end int) *Select {
-	s.limit = []int{begin, end}
+// Limit sets the max count of rows to return
+func (s *Select) Limit(n int) *Select {
+	s.limit = n
 	return s
 }
 
-func (s *Select) toSql() (string, error) {
+// Offset sets the count of rows to skip
+func (s *Select) Offset(n int) *Select {
+	s.offset = n
+	return s
+}
+
+func (s *Select) toSql() (string, []interface{}, error) {
 	var blocks []string
 	if s.cols == nil {
-		return "", errors.New("need selected column names")
+		return "", nil, errors.New("need selected column names")
 	}
 	cols := strings.Join(s.cols, ",")
 	from, err := s.tb.toSql()
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	// select .. from ...join..
 	_select := strings.Join([]string{"SELECT", cols, from}, " ")
 	blocks = append(blocks, _select)
 	// where...
-	if s.where != "" {
-		where := fmt.Sprintf("Where %s", s.where)
+	cond := s.where
+	orders := s.orders
+	if s.cursor != nil {
+		orders = s.seekOrders()
+		keyset, err := keysetExpr(s.tb.db.dialect, orders, s.cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		cond = And(cond, keyset)
+	}
+	where, args, err := whereSql(cond)
+	if err != nil {
+		return "", nil, err
+	}
+	if where != "" {
 		blocks = append(blocks, where)
 	}
+	// group by ... having ...
+	if s.groupCols != nil {
+		blocks = append(blocks, strings.Join([]string{"GROUP BY", strings.Join(s.groupCols, ",")}, " "))
+	}
+	if s.having != nil {
+		having, havingArgs, err := s.having.toSql()
+		if err != nil {
+			return "", nil, err
+		}
+		blocks = append(blocks, strings.Join([]string{"HAVING", having}, " "))
+		args = append(args, havingArgs...)
+	}
 	// order by ...
-	if s.orderCols != nil {
-		asc := "ASC"
-		if s.orderDesc {
-			asc = "DESC"
+	if orders != nil {
+		terms := make([]string, len(orders))
+		for i, o := range orders {
+			var orderArgs []interface{}
+			terms[i], orderArgs = o.toSql(s.tb.db.dialect)
+			args = append(args, orderArgs...)
 		}
-		order := strings.Join([]string{"ORDER BY", strings.Join(s.orderCols, ","), asc}, " ")
+		order := strings.Join([]string{"ORDER BY", strings.Join(terms, ",")}, " ")
 		blocks = append(blocks, order)
 	}
-	// limit ..
-	if s.limit != nil {
-		limit := fmt.Sprintf("LIMIT %d, %d", s.limit[0], s.limit[1])
+	// limit .. offset ..
+	if limit := s.tb.db.dialect.LimitOffset(s.limit, s.offset); limit != "" {
 		blocks = append(blocks, limit)
 	}
-	return strings.Join(blocks, " "), nil
+	return strings.Join(blocks, " "), args, nil
 }
 
 func (s *Select) Get(m isModel) error {
 	if s.err != nil {
 		return s.err
 	}
+	s.tb.markSecrets(m)
 	// get cols from the isModel
 	if s.cols == nil {
 		cols := getColumns(m)
@@ -175,14 +311,22 @@ func (s *Select) Get(m isModel) error {
 			s.err = errors.New("get none columns mapping on the model")
 			return s.err
 		}
-		s.cols = cols
+		s.cols = s.tb.quoteCols(cols)
 	}
 	var q string
-	q, s.err = s.toSql()
+	var args []interface{}
+	q, args, s.err = s.toSql()
+	if s.err != nil {
+		return s.err
+	}
+	s.err = s.tb.db.dbx.Get(s.tb.context(), m, q, args...)
 	if s.err != nil {
 		return s.err
 	}
-	s.err = s.tb.db.dbx.Get(m, q, s.args...)
+	// watch the loaded model so `Update` knows what is changed
+	if tracker, ok := m.(isDirtyTracker); ok {
+		s.err = tracker.track(tracker)
+	}
 	return s.err
 }
 
@@ -190,26 +334,33 @@ func (s *Select) All(models interface{}) error {
 	if s.err != nil {
 		return s.err
 	}
+	tp, err := extractModelType(models)
+	if err != nil {
+		s.err = err
+		return s.err
+	}
+	s.tb.markSecrets(tp)
 	// get cols from the isModel
 	if s.cols == nil {
-		tp, err := extractModelType(models)
-		if err != nil {
-			s.err = err
-			return s.err
-		}
 		cols := getColumns(tp)
 		if cols == nil {
 			s.err = errors.New("get none columns mapping on the model")
 			return s.err
 		}
-		s.cols = cols
+		s.cols = s.tb.quoteCols(cols)
 	}
 	var q string
-	q, s.err = s.toSql()
+	var args []interface{}
+	q, args, s.err = s.toSql()
+	if s.err != nil {
+		return s.err
+	}
+	s.err = s.tb.db.dbx.Select(s.tb.context(), models, q, args...)
 	if s.err != nil {
 		return s.err
 	}
-	s.err = s.tb.db.dbx.Select(models, q, s.args...)
+	s.afterScan(models)
+	s.err = trackAll(models)
 	return s.err
 }
 
@@ -218,12 +369,13 @@ func (s *Select) MapScan(dest map[string]interface{}) error {
 		return s.err
 	}
 	var q string
-	q, s.err = s.toSql()
+	var args []interface{}
+	q, args, s.err = s.toSql()
 	if s.err != nil {
 		return s.err
 	}
 	var rows *sqlx.Rows
-	rows, s.err = s.tb.db.dbx.Queryx(q, s.args...)
+	rows, s.err = s.tb.db.dbx.Queryx(s.tb.context(), q, args...)
 	if s.err != nil {
 		return s.err
 	}
@@ -236,9 +388,172 @@ func (s *Select) MapScan(dest map[string]interface{}) error {
 	return s.err
 }
 
-//func (s *Select) Iter(it Iterator) error {
-//	return nil
-//}
+// aggregate runs `fn(col)` on the rows of the select and scans the result to dest,
+// on a grouped select it runs over the groups, each group gives `fn(col)` of its rows,
+// except that `AVG` is the average of the rows in all the groups
+func (s *Select) aggregate(fn string, col string, dest interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	var q string
+	var args []interface{}
+	var err error
+	agg := *s
+	if agg.groupCols == nil && agg.limit < 0 && agg.offset <= 0 && agg.cursor == nil {
+		agg.cols = []string{fmt.Sprintf("%s(%s)", fn, col)}
+		agg.orders = nil
+		q, args, err = agg.toSql()
+	}else{
+		// the rows of grouped, limited or seeked select come from a sub query
+		// columns of the sub query lost their table alias
+		outerCol := col
+		if i := strings.LastIndex(col, "."); i >= 0 {
+			outerCol = col[i + 1:]
+		}
+		outer := fmt.Sprintf("%s(%s)", fn, outerCol)
+		switch {
+		case col == "*":
+			if agg.cols == nil {
+				agg.cols = []string{"1"}
+			}
+		case agg.groupCols != nil:
+			// each group brings up the aggregate of its rows
+			inner := []string{fmt.Sprintf("%s(%s) AS om_agg_col", fn, col)}
+			outer = fmt.Sprintf("%s(om_agg_col)", fn)
+			if fn == "AVG" {
+				// the average of all the rows rather than of the averages of groups
+				inner = []string{fmt.Sprintf("SUM(%s) AS om_agg_sum", col), fmt.Sprintf("COUNT(%s) AS om_agg_cnt", col)}
+				outer = "SUM(om_agg_sum) * 1.0 / NULLIF(SUM(om_agg_cnt), 0)"
+			}
+			agg.cols = append(append([]string{}, agg.cols...), inner...)
+		case agg.cols == nil:
+			agg.cols = []string{col}
+		case !containsStr(agg.cols, col):
+			agg.cols = append(append([]string{}, agg.cols...), fmt.Sprintf("%s AS om_agg_col", col))
+			outer = fmt.Sprintf("%s(om_agg_col)", fn)
+		}
+		q, args, err = agg.toSql()
+		q = fmt.Sprintf("SELECT %s FROM (%s) om_agg", outer, q)
+	}
+	if err != nil {
+		s.err = err
+		return s.err
+	}
+	s.err = s.tb.db.dbx.Get(s.tb.context(), dest, q, args...)
+	return s.err
+}
+
+// Count returns the count of rows of the select,
+// the count of groups is returned if `GroupBy` is used
+func (s *Select) Count() (int64, error) {
+	var cnt int64
+	err := s.aggregate("COUNT", "*", &cnt)
+	return cnt, err
+}
+
+// Sum returns the sum of the column, 0 if there is no rows
+func (s *Select) Sum(col string) (float64, error) {
+	var sum sql.NullFloat64
+	err := s.aggregate("SUM", col, &sum)
+	return sum.Float64, err
+}
+
+// Avg returns the average of the column, 0 if there is no rows,
+// it is the average of all the rows of the groups on a grouped select
+func (s *Select) Avg(col string) (float64, error) {
+	var avg sql.NullFloat64
+	err := s.aggregate("AVG", col, &avg)
+	return avg.Float64, err
+}
+
+// Min scans the min value of the column to dest,
+// use a `sql.Null*` dest if the select may match no rows
+func (s *Select) Min(col string, dest interface{}) error {
+	return s.aggregate("MIN", col, dest)
+}
+
+// Max scans the max value of the column to dest,
+// use a `sql.Null*` dest if the select may match no rows
+func (s *Select) Max(col string, dest interface{}) error {
+	return s.aggregate("MAX", col, dest)
+}
+
+// Exists tells if the select matches any rows
+func (s *Select) Exists() (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+	sub := *s
+	if sub.cols == nil {
+		sub.cols = []string{"1"}
+	}
+	q, args, err := sub.toSql()
+	if err != nil {
+		s.err = err
+		return false, s.err
+	}
+	var exists bool
+	s.err = s.tb.db.dbx.Get(s.tb.context(), &exists, fmt.Sprintf("SELECT EXISTS(%s)", q), args...)
+	return exists, s.err
+}
+
+// Page scans rows of the page to models and returns the total count of rows,
+// page starts from 1
+//
+// Example:
+//	var books []Book
+//	total, err := db.Tb("book").Select().Where("tag = ?", 99).OrderAsc("id").Page(2, 20, &books)
+func (s *Select) Page(page int, size int, models interface{}) (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	if page < 1 || size < 1 {
+		s.err = fmt.Errorf("invalid page %d with size %d", page, size)
+		return 0, s.err
+	}
+	counter := *s
+	counter.limit = -1
+	counter.offset = 0
+	total, err := counter.Count()
+	if err != nil {
+		s.err = err
+		return 0, s.err
+	}
+	offset := (page - 1) * size
+	// no rows in the page, no query
+	if total <= int64(offset) {
+		return total, nil
+	}
+	return total, s.Limit(size).Offset(offset).All(models)
+}
+
+// Iter returns an iterator to stream rows of the select,
+// all columns are selected if no columns are given,
+// errors come back from `Iterator.Err`,
+// streamed rows can't be reversed, so seeking before a cursor is left to `All`
+func (s *Select) Iter() Iterator {
+	if s.err != nil {
+		return &rowsIterator{err:s.err}
+	}
+	if s.before {
+		return &rowsIterator{err:errors.New("Iter can't seek before a cursor, use All")}
+	}
+	if s.cols == nil {
+		s.cols = []string{"*"}
+	}
+	var q string
+	var args []interface{}
+	q, args, s.err = s.toSql()
+	if s.err != nil {
+		return &rowsIterator{err:s.err}
+	}
+	var rows *sqlx.Rows
+	rows, s.err = s.tb.db.dbx.Queryx(s.tb.context(), q, args...)
+	if s.err != nil {
+		return &rowsIterator{err:s.err}
+	}
+	return &rowsIterator{rows:rows}
+}
 
 type joinInfo struct {
 	// join type
@@ -253,7 +568,7 @@ type joinInfo struct {
 	onRight string
 }
 
-func (info *joinInfo) toSql() (string, error) {
+func (info *joinInfo) toSql(d Dialect) (string, error) {
 	//leftGroup := strings.Split(info.onLeft, ".")
 	onLeft := info.onLeft
 	//if len(leftGroup) == 2 && leftGroup[0] != info.preAlias {
@@ -273,7 +588,7 @@ func (info *joinInfo) toSql() (string, error) {
 	if strings.Contains(info.onLeft,
 		strings.Join([]string{info.preAlias, "."}, "")) {
 	}
-	return strings.Join([]string{info.tp, info.tb, info.alias,
+	return strings.Join([]string{info.tp, quoteIdent(d, info.tb), info.alias,
 		"on", onLeft, "=", onRight}, " "), nil
 }
 
@@ -282,27 +597,42 @@ type Tables struct {
 	alias string
 	db *DB
 	name string
+	// pk column used to fetch inserted ids by `RETURNING`
+	pk string
 	joinInfos []*joinInfo
+	ctx context.Context
+	// secrets are the columns tagged `secret` by the models of the queries
+	secrets map[string]bool
 }
 
 func (t *Tables) toSql() (string, error) {
 	var err error
 	js := make([]string, len(t.joinInfos))
 	for i, join := range t.joinInfos {
-		js[i], err = join.toSql()
+		js[i], err = join.toSql(t.db.dialect)
 		if err != nil {
 			return "", err
 		}
 	}
-	return strings.Join([]string{"FROM", t.name, t.alias,
+	return strings.Join([]string{"FROM", quoteIdent(t.db.dialect, t.name), t.alias,
 		strings.Join(js, " ")}, " "), nil
 }
 
+// quoteCols quotes column names with the dialect of the db
+func (t *Tables) quoteCols(cols []string) []string {
+	quoted := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = quoteIdent(t.db.dialect, col)
+	}
+	return quoted
+}
+
 func NewTables(db *DB, name string, alias...string) *Tables {
 	t := &Tables{
 		db:db,
 		name:name,
 		alias:name,
+		pk:defaultPK,
 	}
 	if len(alias) > 0 {
 		t.alias = alias[0]
@@ -310,6 +640,45 @@ func NewTables(db *DB, name string, alias...string) *Tables {
 	return t
 }
 
+// WithContext sets the context of queries on the tables,
+// it overrides the context of the db
+func (t *Tables) WithContext(ctx context.Context) *Tables {
+	t.ctx = ctx
+	return t
+}
+
+// context returns the context of queries on the table,
+// it's tagged with the table name for hooks and the secret columns for logs
+func (t *Tables) context() context.Context {
+	ctx := t.ctx
+	if ctx == nil {
+		ctx = t.db.context()
+	}
+	if len(t.secrets) > 0 {
+		ctx = withSecrets(ctx, t.secrets)
+	}
+	return withTable(ctx, t.name)
+}
+
+// markSecrets hides the columns tagged `secret` by the model or its type
+// in logs of queries on the table
+func (t *Tables) markSecrets(tOrModel interface{}) {
+	for _, col := range secretCols(tOrModel) {
+		if t.secrets == nil {
+			t.secrets = map[string]bool{}
+		}
+		t.secrets[col] = true
+	}
+}
+
+// PK sets the primary key column of the table,
+// dialects like postgres return the inserted id by the column,
+// an empty column fetches no id for tables without a generated integer pk
+func (t *Tables) PK(col string) *Tables {
+	t.pk = col
+	return t
+}
+
 func (t *Tables)Join(other string, onMyCol string, onOtherCol string, alias...string) *Tables {
 	tp := "INNER JOIN"
 	info := &joinInfo{
@@ -370,76 +739,122 @@ func (t *Tables) Delete(ms ...isModel) *DeferWhere {
 	var m isModel
 	if len(ms) > 0 {
 		m = ms[0]
+		t.markSecrets(m)
+	}
+	// deleting a tracked model is audited
+	_, audited := m.(isDirtyTracker)
+	audited = audited && t.db.audit != nil
+	var manager *Manager
+	if audited {
+		var err error
+		if manager, err = newManager(m); err != nil {
+			t.err = err
+		}
 	}
 	w := &DeferWhere{
 		tb:t,
-		where:"",
-		args:nil,
+		where:nil,
 		cb:func(w *DeferWhere) (int64, error) {
 			// no where condition, no id
-			if w.where == "" {
+			if w.where == nil {
 				holder, ok := m.(idHolder)
 				if !ok {
 					w.tb.err = errors.New("no where condition and no id")
 				}else{
 					colName, id := holder.Identity()
-					// build where string with id
-					w.args = append(w.args, id)
-					w.where = fmt.Sprintf("%s=?", colName)
+					// build where condition with id
+					w.where = Raw(fmt.Sprintf("%s=?", colName), id)
 				}
 			}
 			if w.tb.err != nil {
 				return 0, w.tb.err
 			}
-			return w.tb.delete(w.where, w.args...)
+			if !audited {
+				return w.tb.delete(w.where)
+			}
+			return w.tb.auditChange(AuditDelete, manager, m, w.where, nil, nil, func(t *Tables) (int64, error) {
+				return t.delete(w.where)
+			})
 		},
 	}
 	return w
 }
 
-func (t *Tables) delete(where string, args...interface{}) (int64, error)  {
+func (t *Tables) delete(where isExpr) (int64, error)  {
 	if t.err != nil {
 		return 0, t.err
 	}
-	var whereSql = ""
-	if where != "" {
-		whereSql = fmt.Sprintf("WHERE %s", where)
+	whereSql, args, err := whereSql(where)
+	if err != nil {
+		return 0, err
 	}
-	query := fmt.Sprintf("DELETE FROM %s %s", t.name, whereSql)
-	result, err := t.db.dbx.Exec(query, args...)
+	query := fmt.Sprintf("DELETE FROM %s %s", quoteIdent(t.db.dialect, t.name), whereSql)
+	result, err := t.db.dbx.Exec(t.context(), query, args...)
 	if err!= nil {
 		return 0, err
 	}
 	return result.RowsAffected()
 }
 
+// Update updates the columns of the model,
+// only dirty columns are updated if the model embeds `Tracking` and is watched,
+// the statement is skipped if nothing changed, and the model is watched again after updated
 func (t *Tables) Update(m isModel) *DeferWhere {
+	t.markSecrets(m)
 	manager, err := newManager(m)
 	if err != nil {
 		t.err = err
 	}
+	cols := manager.cols
+	tracker, tracked := m.(isDirtyTracker)
+	audited := tracked && t.db.audit != nil
+	dirtyOnly := false
+	if tracked {
+		// no snapshot to diff with, update all columns
+		if dirty, err := tracker.dirtyFields(tracker); err == nil {
+			cols = manager.dirtyCols(dirty, tracker)
+			dirtyOnly = true
+		}
+	}
 	w := &DeferWhere{
 		tb:t,
+		cols:cols,
 		colsMap:manager.ColsMap(),
-		where:"",
-		args:nil,
+		where:nil,
 		cb:func(w *DeferWhere)(int64, error) {
 			// no where condition, no id
-			if w.where == "" {
+			if w.where == nil {
 				holder, ok := m.(idHolder)
 				if !ok {
 					w.tb.err = errors.New("no where condition and no id")
 				}else{
 					colName, id := holder.Identity()
-					// build where string with id
-					w.args = append(w.args, id)
-					w.where = fmt.Sprintf("%s=?", colName)
+					// build where condition with id
+					w.where = Raw(fmt.Sprintf("%s=?", colName), id)
 				}
 			}
 			if w.tb.err != nil {
 				return 0, w.tb.err
 			}
-			cnt, err := t.update(w.colsMap, w.where, w.args...)
+			if dirtyOnly && len(w.cols) == 0 {
+				return 0, nil
+			}
+			update := func(t *Tables) (int64, error) {
+				return t.update(w.cols, w.colsMap, w.where)
+			}
+			var cnt int64
+			var err error
+			if audited {
+				cnt, err = w.tb.auditChange(AuditUpdate, manager, m, w.where, w.cols, w.colsMap, update)
+			} else {
+				cnt, err = update(w.tb)
+			}
+			if err != nil {
+				return cnt, err
+			}
+			if tracked {
+				err = tracker.track(tracker)
+			}
 			return cnt, err
 		},
 	}
@@ -450,13 +865,12 @@ func (t *Tables) UpdateMap(colsMap map[string]interface{}) *DeferWhere {
 	w := &DeferWhere{
 		tb:t,
 		colsMap:colsMap,
-		where:"",
-		args:nil,
+		where:nil,
 		cb:func(w *DeferWhere) (int64, error) {
 			if w.tb.err != nil {
 				return 0, w.tb.err
 			}
-			return w.tb.update(w.colsMap, w.where, w.args...)
+			return w.tb.update(w.cols, w.colsMap, w.where)
 		},
 	}
 	return w
@@ -465,13 +879,14 @@ func (t *Tables) UpdateMap(colsMap map[string]interface{}) *DeferWhere {
 func (t *Tables) InsertMap(colsMap map[string]interface{}) Donner {
 	e := &executor{
 		callback:func() (int64, error){
-			return t.insert(colsMap)
+			return t.insert(t.pk, nil, colsMap)
 		},
 	}
 	return e
 }
 
 func (t *Tables) Insert(m isModel) Donner {
+	t.markSecrets(m)
 	manager, err := newManager(m)
 	if err != nil {
 		t.err = err
@@ -481,60 +896,224 @@ func (t *Tables) Insert(m isModel) Donner {
 			if t.err != nil {
 				return 0, t.err
 			}
-			id, err := t.insert(manager.ColsMap())
-			if err != nil {
-				t.err = err
-				return id, t.err
+			pk := t.pk
+			if manager.pk != "" {
+				pk = manager.pk
+				// only generated integers are fetched back
+				if !isInteger(manager.colInfoMap[pk].Field.Type) {
+					pk = ""
+				}
 			}
-			manager.Bind(id)
-			return id, t.err
+			cols, colsMap := manager.insertColsMap()
+			if _, tracked := m.(isDirtyTracker); !tracked || t.db.audit == nil {
+				id, err := t.insert(pk, cols, colsMap)
+				if err != nil {
+					t.err = err
+					return id, t.err
+				}
+				manager.Bind(id)
+				return id, nil
+			}
+			var id int64
+			err := t.auditTx(func(t *Tables) (err error) {
+				if id, err = t.insert(pk, cols, colsMap); err != nil {
+					return err
+				}
+				manager.Bind(id)
+				return t.audit(AuditInsert, pkOf(manager, m), nil, manager.ColsMap())
+			})
+			return id, err
 		},
 	}
 	return e
 }
 
-func (t *Tables) insert(colsMaps ...map[string]interface{}) (int64, error) {
-	if t.err != nil {
-		return 0, t.err
+// InsertMaps inserts the rows by multi-row INSERTs, all maps must have the same columns,
+// rows are split into chunks under the placeholder limit of the dialect,
+// `Done` returns the total rows inserted, chunks are not atomic unless in a `Tx`
+//
+// Example:
+//	n, err := db.Tb("book").InsertMaps(
+//		map[string]interface{}{"name": "Python", "tag": 99},
+//		map[string]interface{}{"name": "Golang", "tag": 88},
+//	).Done()
+func (t *Tables) InsertMaps(colsMaps ...map[string]interface{}) Donner {
+	e := &executor{
+		callback:func() (int64, error){
+			return t.insertMany(nil, colsMaps)
+		},
 	}
+	return e
+}
 
+// InsertMany inserts the models by multi-row INSERTs like `InsertMaps`,
+// the pk column is left out if it's zero in all the models,
+// inserted ids are not bound to the models
+func (t *Tables) InsertMany(models ...isModel) Donner {
+	colsMaps := make([]map[string]interface{}, 0, len(models))
+	var cols []string
+	zeroPKs := 0
+	for _, m := range models {
+		t.markSecrets(m)
+		manager, err := newManager(m)
+		if err != nil {
+			t.err = err
+			break
+		}
+		if manager.zeroPK() {
+			zeroPKs++
+		}
+		var colsMap map[string]interface{}
+		cols, colsMap = manager.insertColsMap()
+		colsMaps = append(colsMaps, colsMap)
+	}
+	if zeroPKs > 0 && zeroPKs < len(models) && t.err == nil {
+		t.err = errors.New("can't insert models with zero and non-zero pk together")
+	}
+	e := &executor{
+		callback:func() (int64, error){
+			if t.err != nil {
+				return 0, t.err
+			}
+			return t.insertMany(cols, colsMaps)
+		},
+	}
+	return e
+}
+
+// containsStr tells if the string is in the slice
+func containsStr(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedCols returns the columns of the map in order
+func sortedCols(colsMap map[string]interface{}) []string {
+	cols := make([]string, 0, len(colsMap))
+	for col := range colsMap {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// insertSql makes a multi-row INSERT of the maps,
+// values of each map are bound in the order of cols,
+// which are the sorted columns of the first map if nil
+func (t *Tables) insertSql(cols []string, colsMaps []map[string]interface{}) (string, []interface{}, error) {
 	if len(t.joinInfos) > 0 {
-		return 0, errors.New("un supportted join insert")
+		return "", nil, errors.New("un supportted join insert")
 	}
 	if len(colsMaps) == 0 {
-		return 0, errors.New("no data to be insert")
+		return "", nil, errors.New("no data to be insert")
+	}
+	if cols == nil {
+		cols = sortedCols(colsMaps[0])
+	}
+	if len(cols) == 0 {
+		return "", nil, errors.New("no columns")
 	}
 	var colNames []string
+	for _, name := range cols {
+		colNames = append(colNames, quoteIdent(t.db.dialect, name))
+	}
 	var args []interface{}
 	var values []string
 	for i, aMap := range colsMaps {
-		for name, arg := range aMap {
-			if i == 0 {
-				colNames = append(colNames, name)
+		if len(aMap) != len(cols) {
+			return "", nil, fmt.Errorf("row %d has %d columns, expect %d", i, len(aMap), len(cols))
+		}
+		for _, name := range cols {
+			arg, ok := aMap[name]
+			if !ok {
+				return "", nil, fmt.Errorf("row %d has no column %s", i, name)
 			}
 			args = append(args, arg)
 		}
-		bs := bytes.Repeat([]byte{'?',','}, len(aMap))
+		bs := bytes.Repeat([]byte{'?',','}, len(cols))
 		bs[len(bs) - 1] = ')'
 		values = append(values, strings.Join([]string{"(", string(bs)},""))
 	}
 	// Values: VALUES (?,?),(?,?),...
 	valuesSql := fmt.Sprintf("VALUES %s", strings.Join(values, ","))
-	if len(colNames) == 0 {
-		return 0, errors.New("no columns")
-	}
 	// columns: colA, colB, colC,...
 	names := strings.Join(colNames, ",")
-	sql := fmt.Sprintf("INSERT INTO %s(%s) %s", t.name, names, valuesSql)
-	result, err := t.db.dbx.Exec(sql, args...)
+	sql := fmt.Sprintf("INSERT INTO %s(%s) %s",
+		quoteIdent(t.db.dialect, t.name), names, valuesSql)
+	return sql, args, nil
+}
+
+func (t *Tables) insert(pk string, cols []string, colsMaps ...map[string]interface{}) (int64, error) {
+	if t.err != nil {
+		return 0, t.err
+	}
+	sql, args, err := t.insertSql(cols, colsMaps)
 	if err != nil {
 		return 0, err
 	}
+	// the dialect may return the inserted pk by the insert statement itself
+	if returning := t.db.dialect.Returning(pk); returning != "" {
+		var id int64
+		err := t.db.dbx.Get(t.context(), &id, strings.Join([]string{sql, returning}, " "), args...)
+		return id, err
+	}
+	result, err := t.db.dbx.Exec(t.context(), sql, args...)
+	if err != nil {
+		return 0, err
+	}
+	// no pk is fetched without `RETURNING` of a dialect using it
+	if pk == "" && t.db.dialect.Returning(defaultPK) != "" {
+		return 0, nil
+	}
 	return result.LastInsertId()
 }
 
-func (t *Tables) update(colsMap map[string]interface{},
-	where string, whereArgs...interface{}) (int64, error) {
+// insertMany inserts the rows chunk by chunk, returns the total rows inserted
+func (t *Tables) insertMany(cols []string, colsMaps []map[string]interface{}) (int64, error) {
+	if t.err != nil {
+		return 0, t.err
+	}
+	if len(colsMaps) == 0 {
+		return 0, nil
+	}
+	size := len(colsMaps)
+	if cols := len(colsMaps[0]); cols > 0 {
+		size = t.db.dialect.MaxPlaceholders() / cols
+	}
+	if size < 1 {
+		return 0, fmt.Errorf("too many columns to insert:%d", len(colsMaps[0]))
+	}
+	var total int64
+	for begin := 0; begin < len(colsMaps); begin += size {
+		end := begin + size
+		if end > len(colsMaps) {
+			end = len(colsMaps)
+		}
+		sql, args, err := t.insertSql(cols, colsMaps[begin:end])
+		if err != nil {
+			return total, err
+		}
+		result, err := t.db.dbx.Exec(t.context(), sql, args...)
+		if err != nil {
+			return total, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// update sets the columns by the order of cols,
+// which are the sorted columns of the map if nil
+func (t *Tables) update(cols []string, colsMap map[string]interface{},
+	where isExpr) (int64, error) {
 	if t.err != nil {
 		return 0, t.err
 	}
@@ -545,21 +1124,23 @@ func (t *Tables) update(colsMap map[string]interface{},
 	if len(colsMap) == 0 {
 		return 0, errors.New("no data to execute")
 	}
-	var cols []string
+	if cols == nil {
+		cols = sortedCols(colsMap)
+	}
+	var sets []string
 	var args []interface{}
-	for name, arg := range colsMap {
-		cols = append(cols, fmt.Sprintf("%s=?", name))
-		args = append(args, arg)
+	for _, name := range cols {
+		sets = append(sets, fmt.Sprintf("%s=?", quoteIdent(t.db.dialect, name)))
+		args = append(args, colsMap[name])
 	}
-	whereSql := ""
-	if where != "" {
-		whereSql = fmt.Sprintf("WHERE %s", where)
-		for _, arg := range whereArgs {
-			args = append(args, arg)
-		}
+	whereSql, whereArgs, err := whereSql(where)
+	if err != nil {
+		return 0, err
 	}
-	sql := fmt.Sprintf("UPDATE %s SET %s %s", t.name, strings.Join(cols, ","), whereSql)
-	result, err := t.db.dbx.Exec(sql, args...)
+	args = append(args, whereArgs...)
+	sql := fmt.Sprintf("UPDATE %s SET %s %s",
+		quoteIdent(t.db.dialect, t.name), strings.Join(sets, ","), whereSql)
+	result, err := t.db.dbx.Exec(t.context(), sql, args...)
 	if err != nil {
 		return 0, err
 	}
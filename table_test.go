@@ -3,47 +3,42 @@ package om
 import (
 	"testing"
 	"os"
-	"fmt"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/mattn/go-sqlite3"
 	"database/sql"
+	"reflect"
+	"context"
+	"errors"
+	"time"
+	"fmt"
+	"strings"
+	"bytes"
+	"encoding/json"
+	"math"
+	"github.com/Sirupsen/logrus"
 )
 
-func init()  {
-	// todo: remove this constant env key here
-	os.Setenv("DBUTILS_MYSQL_DNS", "root:akun@123@(vagrant:3306)/test?charset=utf8")
-
-	ConnectAll()
-}
-
-// clean testing tx
-func clean (tx *sqlx.Tx){
-	re := recover()
-	var e error
-	var ok bool
-	if e, ok = re.(error); ok {
-		fmt.Println("panic:", e)
-		tx.Rollback()
-	}else{
-		tx.Commit()
-	}
-	if e != nil {
-		panic(e)
-	}
-}
-
-var TestMysql = true
-var mysqlDB *sqlx.DB
+// mysqlDSN enables tests on mysql besides the in-memory sqlite,
+// e.g. `DBUTILS_MYSQL_DNS="root:pwd@(localhost:3306)/test?charset=utf8"`
+var mysqlDSN = os.Getenv("DBUTILS_MYSQL_DNS")
 
 type Scheme struct {
 	create []string
 	drop string
+
+	sqliteCreate []string
+	sqliteDrop string
 }
 
 func (p Scheme) Mysql() ([]string, string) {
 	return p.create, p.drop
 }
 
+func (p Scheme) Sqlite() ([]string, string) {
+	return p.sqliteCreate, p.sqliteDrop
+}
+
 var t_book = "test_book"
 var t_author = "test_author"
 
@@ -63,12 +58,30 @@ var test_scheme = Scheme{
 	  age INT NULL,
 	  deleted BOOLEAN DEFAULT FALSE);`},
 	drop:"drop table test_book, test_author; ",
+
+	sqliteCreate: []string{
+		`
+	CREATE TABLE test_book(
+	  id INTEGER PRIMARY KEY AUTOINCREMENT,
+	  name VARCHAR(100) NOT NULL,
+	  tag TINYINT NULL,
+	  deleted BOOLEAN DEFAULT FALSE,
+	  author_id INT NULL
+	);`,`
+	CREATE TABLE test_author(
+	  id INTEGER PRIMARY KEY AUTOINCREMENT,
+	  name VARCHAR(100) NOT NULL,
+	  age INT NULL,
+	  deleted BOOLEAN DEFAULT FALSE);`},
+	sqliteDrop:"drop table test_book; drop table test_author;",
 }
 
 type testFunc func(db *sqlx.DB, t *testing.T)
 
+// RunWithScheme runs the test function on a fresh in-memory sqlite db,
+// and on mysql too if `mysqlDSN` is set
 func RunWithScheme(scheme Scheme, t *testing.T, testFn testFunc)  {
-	runner := func(db *sqlx.DB, create []string, drop string){
+	runner := func(t *testing.T, db *sqlx.DB, create []string, drop string){
 		// drop tables
 		defer func(){
 			db.MustExec(drop)
@@ -79,25 +92,29 @@ func RunWithScheme(scheme Scheme, t *testing.T, testFn testFunc)  {
 			db.MustExec(query)
 		}
 		// run test function
-		testFn(mysqlDB, t)
+		testFn(db, t)
 	}
 
-	if TestMysql {
-		create, drop := scheme.Mysql()
-		runner(mysqlDB, create, drop)
-	}
-}
-
-func ConnectAll()  {
-	mysqlDNS := os.Getenv("DBUTILS_MYSQL_DNS")
-
-	if TestMysql {
-		db, err := sqlx.Open("mysql", mysqlDNS)
+	t.Run("sqlite3", func(t *testing.T) {
+		db, err := sqlx.Open("sqlite3", ":memory:")
 		if err != nil {
-			fmt.Printf("\nfail to connect mysql, err:%v\n", err)
-			TestMysql = false
+			t.Fatalf("fail to open sqlite, err:%v", err)
 		}
-		mysqlDB = db
+		// every connection of `:memory:` owns a separated database
+		db.SetMaxOpenConns(1)
+		create, drop := scheme.Sqlite()
+		runner(t, db, create, drop)
+	})
+
+	if mysqlDSN != "" {
+		t.Run("mysql", func(t *testing.T) {
+			db, err := sqlx.Open("mysql", mysqlDSN)
+			if err != nil {
+				t.Fatalf("fail to connect mysql, err:%v", err)
+			}
+			create, drop := scheme.Mysql()
+			runner(t, db, create, drop)
+		})
 	}
 }
 
@@ -141,6 +158,342 @@ func TestTables_Insert(t *testing.T) {
 	})
 }
 
+func TestTables_InsertReturning(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		if sb.DriverName() != "sqlite3" {
+			return
+		}
+		// sqlite runs the statements of postgres with `RETURNING`
+		rec := &queryRecorder{}
+		db := NewDB(sb, WithDialect(Postgres), WithLogger(rec))
+		id, err := db.Tb(t_book).Insert(&tTrackedBook{Name: "Python"}).Done()
+		if err != nil || id != 1 || !strings.HasSuffix(rec.queries[0], `RETURNING "id"`) {
+			t.Errorf("expect id 1 by RETURNING, got:%d, %v, err:%v", id, rec.queries, err)
+		}
+
+		// no pk is fetched if it isn't an integer or is opted out
+		type Book struct {
+			M
+			Name string `db:"name,pk"`
+		}
+		rec.queries = nil
+		id, err = db.Tb(t_book).Insert(&Book{Name: "Golang"}).Done()
+		if err != nil || id != 0 || strings.Contains(rec.queries[0], "RETURNING") {
+			t.Errorf("expect no RETURNING, got:%d, %v, err:%v", id, rec.queries, err)
+		}
+		rec.queries = nil
+		_, err = db.Tb(t_book).PK("").InsertMap(map[string]interface{}{"name": "Rust"}).Done()
+		if err != nil || strings.Contains(rec.queries[0], "RETURNING") {
+			t.Errorf("expect no RETURNING, got:%v, err:%v", rec.queries, err)
+		}
+	})
+}
+
+func TestTables_InsertMany(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		db := NewDB(sb)
+		// 1200 rows of 2 columns are more than one chunk of any dialect
+		var maps []map[string]interface{}
+		for i := 0; i < 1200; i++ {
+			maps = append(maps, map[string]interface{}{"name": fmt.Sprintf("book%d", i), "tag": i % 100})
+		}
+		n, err := db.Tb(t_book).InsertMaps(maps...).Done()
+		if err != nil || n != 1200 {
+			t.Errorf("expect 1200 rows, got:%d, err:%v", n, err)
+		}
+		var book tBook
+		err = db.Tb(t_book).Select("name", "tag").Where("name = ?", "book1199").Get(&book)
+		if err != nil || book.Tag != 99 {
+			t.Errorf("expect tag 99, got:%+v, err:%v", book, err)
+		}
+
+		n, err = db.Tb(t_book).InsertMany(&tBook{Name: "Python", Tag: 1}, &tBook{Name: "Golang", Tag: 2}).Done()
+		if err != nil || n != 2 {
+			t.Errorf("expect 2 rows, got:%d, err:%v", n, err)
+		}
+		cnt, err := db.Tb(t_book).Select().Count()
+		if err != nil || cnt != 1202 {
+			t.Errorf("expect 1202 rows, got:%d, err:%v", cnt, err)
+		}
+
+		// rows must have the same columns
+		_, err = db.Tb(t_book).InsertMaps(
+			map[string]interface{}{"name": "Rust"},
+			map[string]interface{}{"tag": 1},
+		).Done()
+		if err == nil {
+			t.Errorf("expect err of different columns")
+		}
+
+		// the pk is resolved as `Insert` does, zero and non-zero pks can't be mixed
+		type Book struct {
+			M
+			ID   int64  `db:"id"`
+			Name string `db:"name"`
+		}
+		_, err = db.Tb(t_book).InsertMany(&Book{Name: "Rust"}, &Book{ID: 99999, Name: "Java"}).Done()
+		if err == nil {
+			t.Errorf("expect err of zero and non-zero pks")
+		}
+	})
+}
+
+type queryRecorder struct {
+	nopLogger
+	queries []string
+}
+
+func (r *queryRecorder) Debug(spec string, query string, args []interface{}, elapsed time.Duration) {
+	r.queries = append(r.queries, query)
+}
+
+func TestTables_ColumnOrder(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		rec := &queryRecorder{}
+		db := NewDB(sb, WithLogger(rec))
+		q := func(ident string) string {
+			return quoteIdent(db.Dialect(), ident)
+		}
+		type Book struct {
+			M
+			Tag int `db:"tag"`
+			Name string `db:"name"`
+		}
+		_, err := db.Tb(t_book).Insert(&Book{Tag: 1, Name: "Python"}).Done()
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		_, err = db.Tb(t_book).Update(&Book{Tag: 2, Name: "Golang"}).Where("name = ?", "Python").Done()
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		// map based APIs are in sorted key order
+		_, err = db.Tb(t_book).UpdateMap(map[string]interface{}{"tag": 3, "name": "Rust", "deleted": true}).
+			Where("name = ?", "Golang").Done()
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		expect := []string{
+			fmt.Sprintf("INSERT INTO %s(%s,%s) VALUES (?,?)", q(t_book), q("tag"), q("name")),
+			fmt.Sprintf("UPDATE %s SET %s=?,%s=? WHERE name = ?", q(t_book), q("tag"), q("name")),
+			fmt.Sprintf("UPDATE %s SET %s=?,%s=?,%s=? WHERE name = ?", q(t_book), q("deleted"), q("name"), q("tag")),
+		}
+		if len(rec.queries) != len(expect) {
+			t.Fatalf("expect %d queries, got:%v", len(expect), rec.queries)
+		}
+		for i, query := range rec.queries {
+			if !strings.HasPrefix(query, expect[i]) {
+				t.Errorf("expect %s, got:%s", expect[i], query)
+			}
+		}
+	})
+}
+
+type tTrackedBook struct {
+	M
+	Tracking
+	Id int64 `db:"id,pk"`
+	Name string `db:"name"`
+	Tag int `db:"tag"`
+}
+
+func TestTables_UpdateDirty(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		rec := &queryRecorder{}
+		db := NewDB(sb, WithLogger(rec))
+		q := func(ident string) string {
+			return quoteIdent(db.Dialect(), ident)
+		}
+		book := tTrackedBook{Name: "Python", Tag: 1}
+		_, err := db.Tb(t_book).Insert(&book).Done()
+		if err != nil {
+			t.Fatalf("got err:%v", err)
+		}
+
+		// no snapshot, all columns are updated
+		rec.queries = nil
+		_, err = db.Tb(t_book).Update(&book).Where("id = ?", book.Id).Done()
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		expect := fmt.Sprintf("UPDATE %s SET %s=?,%s=?,%s=? ", q(t_book), q("id"), q("name"), q("tag"))
+		if len(rec.queries) != 1 || !strings.HasPrefix(rec.queries[0], expect) {
+			t.Errorf("expect %s, got:%v", expect, rec.queries)
+		}
+
+		// the model is watched after updated, so only the changed column is sent
+		rec.queries = nil
+		book.Tag = 2
+		cnt, err := db.Tb(t_book).Update(&book).Where("id = ?", book.Id).Done()
+		if err != nil || cnt != 1 {
+			t.Errorf("expect 1 row, got:%d, err:%v", cnt, err)
+		}
+		expect = fmt.Sprintf("UPDATE %s SET %s=? ", q(t_book), q("tag"))
+		if len(rec.queries) != 1 || !strings.HasPrefix(rec.queries[0], expect) {
+			t.Errorf("expect %s, got:%v", expect, rec.queries)
+		}
+
+		// nothing changed, nothing sent
+		rec.queries = nil
+		cnt, err = db.Tb(t_book).Update(&book).Where("id = ?", book.Id).Done()
+		if err != nil || cnt != 0 || len(rec.queries) != 0 {
+			t.Errorf("expect no update, got:%d, queries:%v, err:%v", cnt, rec.queries, err)
+		}
+
+		var got tBook
+		err = db.Tb(t_book).Select("name", "tag").Where("id = ?", book.Id).Get(&got)
+		if err != nil || got.Name != "Python" || got.Tag != 2 {
+			t.Errorf("expect Python with tag 2, got:%+v, err:%v", got, err)
+		}
+
+		// a copy of the watched model is diffed on itself
+		books := []tTrackedBook{book}
+		for _, b := range books {
+			b.Tag = 42
+			cnt, err = db.Tb(t_book).Update(&b).Where("id = ?", b.Id).Done()
+			if err != nil || cnt != 1 {
+				t.Errorf("expect 1 row of the copy, got:%d, err:%v", cnt, err)
+			}
+		}
+		err = db.Tb(t_book).Select("name", "tag").Where("id = ?", book.Id).Get(&got)
+		if err != nil || got.Tag != 42 {
+			t.Errorf("expect tag 42, got:%+v, err:%v", got, err)
+		}
+	})
+}
+
+type tOptOutBook struct {
+	M
+	Tracking
+	Id   int64  `db:"id,pk"`
+	Name string `db:"name" om:"-"`
+	Tag  int    `db:"tag"`
+}
+
+type tBookBase struct {
+	Name string `db:"name"`
+}
+
+type tEmbedBook struct {
+	M
+	Tracking
+	tBookBase
+	Id  int64 `db:"id,pk"`
+	Tag int   `db:"tag"`
+}
+
+func TestTables_UpdateUntracked(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		rec := &queryRecorder{}
+		db := NewDB(sb, WithLogger(rec))
+		q := func(ident string) string {
+			return quoteIdent(db.Dialect(), ident)
+		}
+		book := tOptOutBook{Name: "Python", Tag: 1}
+		if _, err := db.Tb(t_book).Insert(&book).Done(); err != nil {
+			t.Fatalf("got err:%v", err)
+		}
+		Watch(&book)
+
+		// columns of fields opted out of tracking are always written
+		rec.queries = nil
+		book.Name = "Golang"
+		cnt, err := db.Tb(t_book).Update(&book).Where("id = ?", book.Id).Done()
+		expect := fmt.Sprintf("UPDATE %s SET %s=? ", q(t_book), q("name"))
+		if err != nil || cnt != 1 || len(rec.queries) != 1 || !strings.HasPrefix(rec.queries[0], expect) {
+			t.Errorf("expect %s, got:%d, %v, err:%v", expect, cnt, rec.queries, err)
+		}
+		rec.queries = nil
+		book.Name = "Rust"
+		book.Tag = 2
+		_, err = db.Tb(t_book).Update(&book).Where("id = ?", book.Id).Done()
+		expect = fmt.Sprintf("UPDATE %s SET %s=?,%s=? ", q(t_book), q("name"), q("tag"))
+		if err != nil || len(rec.queries) != 1 || !strings.HasPrefix(rec.queries[0], expect) {
+			t.Errorf("expect %s, got:%v, err:%v", expect, rec.queries, err)
+		}
+
+		var got tBook
+		err = db.Tb(t_book).Select("name", "tag").Where("id = ?", book.Id).Get(&got)
+		if err != nil || got.Name != "Rust" || got.Tag != 2 {
+			t.Errorf("expect Rust with tag 2, got:%+v, err:%v", got, err)
+		}
+
+		// fields promoted from an unexported struct aren't tracked either
+		embed := tEmbedBook{Id: book.Id, Tag: 2}
+		embed.Name = "Rust"
+		Watch(&embed)
+		embed.Name = "Java"
+		rec.queries = nil
+		cnt, err = db.Tb(t_book).Update(&embed).Where("id = ?", embed.Id).Done()
+		expect = fmt.Sprintf("UPDATE %s SET %s=? ", q(t_book), q("name"))
+		if err != nil || cnt != 1 || len(rec.queries) != 1 || !strings.HasPrefix(rec.queries[0], expect) {
+			t.Errorf("expect %s, got:%d, %v, err:%v", expect, cnt, rec.queries, err)
+		}
+	})
+}
+
+func TestSelect_Track(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		rec := &queryRecorder{}
+		db := NewDB(sb, WithLogger(rec))
+		q := func(ident string) string {
+			return quoteIdent(db.Dialect(), ident)
+		}
+		_, err := db.Tb(t_book).InsertMaps(
+			map[string]interface{}{"name": "Python", "tag": 1},
+			map[string]interface{}{"name": "Golang", "tag": 2},
+		).Done()
+		if err != nil {
+			t.Fatalf("got err:%v", err)
+		}
+		expect := fmt.Sprintf("UPDATE %s SET %s=? ", q(t_book), q("tag"))
+
+		var book tTrackedBook
+		err = db.Tb(t_book).Select().Where("name = ?", "Python").Get(&book)
+		if err != nil {
+			t.Fatalf("got err:%v", err)
+		}
+		rec.queries = nil
+		book.Tag = 10
+		_, err = db.Tb(t_book).Update(&book).Where("id = ?", book.Id).Done()
+		if err != nil || len(rec.queries) != 1 || !strings.HasPrefix(rec.queries[0], expect) {
+			t.Errorf("expect %s, got:%v, err:%v", expect, rec.queries, err)
+		}
+
+		// elements of both values and pointers are watched
+		var books []tTrackedBook
+		var ptrs []*tTrackedBook
+		if err := db.Tb(t_book).Select().OrderAsc("id").All(&books); err != nil {
+			t.Fatalf("got err:%v", err)
+		}
+		if err := db.Tb(t_book).Select().OrderAsc("id").All(&ptrs); err != nil {
+			t.Fatalf("got err:%v", err)
+		}
+		for _, b := range []*tTrackedBook{&books[1], ptrs[1]} {
+			rec.queries = nil
+			b.Tag++
+			_, err = db.Tb(t_book).Update(b).Where("id = ?", b.Id).Done()
+			if err != nil || len(rec.queries) != 1 || !strings.HasPrefix(rec.queries[0], expect) {
+				t.Errorf("expect %s, got:%v, err:%v", expect, rec.queries, err)
+			}
+		}
+
+		// a copied element is written too, even after the slice grows
+		books = append(books, tTrackedBook{})
+		for _, b := range books[:2] {
+			b.Tag = 77
+			cnt, err := db.Tb(t_book).Update(&b).Where("id = ?", b.Id).Done()
+			if err != nil || cnt != 1 {
+				t.Errorf("expect 1 row of the copy, got:%d, err:%v", cnt, err)
+			}
+		}
+		cnt, err := db.Tb(t_book).Select().Where("tag = ?", 77).Count()
+		if err != nil || cnt != 2 {
+			t.Errorf("expect 2 rows of tag 77, got:%d, err:%v", cnt, err)
+		}
+	})
+}
+
 func TestSelect_All(t *testing.T) {
 	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
 		db := NewDB(sb)
@@ -371,6 +724,902 @@ func TestTables_Update(t *testing.T) {
 	})
 }
 
+func TestSelect_WhereExpr(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		db := NewDB(sb)
+		set := []*tBook{
+			&tBook{Name:"Python", Tag:99},
+			&tBook{Name:"Golang", Tag:99},
+			&tBook{Name:"Tencent", Tag:88},
+		}
+		for _, book:=range set {
+			_, err := db.Tb(t_book).Insert(book).Done()
+			if err != nil {
+				t.Errorf("fail to insert, err:%v", err)
+			}
+		}
+		b := bookFields
+		var books []tBook
+		err := db.Tb(t_book).Select().Where(
+			Or(b.Tag.Eq(88), And(b.Tag.Eq(99), b.Name.Like("G%")))).
+			OrderAsc("name").All(&books)
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		if len(books) != 2 || books[0].Name != "Golang" || books[1].Name != "Tencent" {
+			t.Errorf("expect Golang and Tencent, got:%+v", books)
+		}
+
+		// conditions built step by step
+		books = nil
+		err = db.Tb(t_book).Select().Where("tag = ?", 99).
+			AndWhere(b.Name.Like("P%")).OrWhere(b.Name.Eq("Tencent")).
+			OrderAsc("name").All(&books)
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		if len(books) != 2 || books[0].Name != "Python" || books[1].Name != "Tencent" {
+			t.Errorf("expect Python and Tencent, got:%+v", books)
+		}
+
+		cnt, err := db.Tb(t_book).UpdateMap(map[string]interface{}{"tag": 1}).
+			Where(b.Tag.Eq(99)).AndWhere("name <> ?", "Python").Done()
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		if cnt != 1 {
+			t.Errorf("expect update 1 row, got :%d", cnt)
+		}
+
+		cnt, err = db.Tb(t_book).Delete().Where(b.Name.In([]string{"Python", "Golang"})).Done()
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		if cnt != 2 {
+			t.Errorf("expect delete 2 rows, got :%d", cnt)
+		}
+	})
+}
+
+func TestSelect_Aggregate(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		db := NewDB(sb)
+		book_set := []map[string]interface{}{
+			{"name":"Python", "tag":99, "author_id":1},
+			{"name":"Golang", "tag":99, "author_id":2},
+			{"name":"Tencent", "tag":88, "author_id":1},
+		}
+		for _, book := range book_set {
+			_, err := db.Tb(t_book).InsertMap(book).Done()
+			if err != nil {
+				t.Errorf("fail to insert books, err:%v", err)
+			}
+		}
+		cnt, err := db.Tb(t_book).Select().Where("tag = ?", 99).Count()
+		if err != nil || cnt != 2 {
+			t.Errorf("expect count 2, got:%d, err:%v", cnt, err)
+		}
+		// count of groups
+		cnt, err = db.Tb(t_book).Select("author_id").GroupBy("author_id").
+			Having("COUNT(*) > ?", 1).Count()
+		if err != nil || cnt != 1 {
+			t.Errorf("expect count 1, got:%d, err:%v", cnt, err)
+		}
+		var rows []struct{
+			AuthorID int `db:"author_id"`
+			Cnt int `db:"cnt"`
+		}
+		err = db.Tb(t_book).Select("author_id", "COUNT(*) AS cnt").GroupBy("author_id").
+			Having("COUNT(*) > ?", 1).All(&rows)
+		if err != nil || len(rows) != 1 || rows[0].AuthorID != 1 || rows[0].Cnt != 2 {
+			t.Errorf("expect author 1 with 2 books, got:%+v, err:%v", rows, err)
+		}
+		sum, err := db.Tb(t_book).Select().Sum("tag")
+		if err != nil || sum != 286 {
+			t.Errorf("expect sum 286, got:%v, err:%v", sum, err)
+		}
+		// the column isn't selected by the grouped or limited select
+		sum, err = db.Tb(t_book).Select("author_id").GroupBy("author_id").Sum("tag")
+		if err != nil || sum != 286 {
+			t.Errorf("expect sum 286 of groups, got:%v, err:%v", sum, err)
+		}
+		sum, err = db.Tb(t_book).Select("author_id").GroupBy("author_id").
+			Having("COUNT(*) > ?", 1).Sum("tag")
+		if err != nil || sum != 187 {
+			t.Errorf("expect sum 187 of author 1, got:%v, err:%v", sum, err)
+		}
+		// the average of the rows of groups in different sizes
+		avg, err := db.Tb(t_book).Select("author_id").GroupBy("author_id").Avg("tag")
+		if err != nil || math.Abs(avg - 286.0 / 3) > 1e-9 {
+			t.Errorf("expect avg %v, got:%v, err:%v", 286.0 / 3, avg, err)
+		}
+		var maxTag int
+		err = db.Tb(t_book).Select().GroupBy("author_id").Max("tag", &maxTag)
+		if err != nil || maxTag != 99 {
+			t.Errorf("expect max 99, got:%v, err:%v", maxTag, err)
+		}
+		sum, err = db.Tb(t_book).Select("name").OrderAsc("name").Limit(2).Sum("tag")
+		if err != nil || sum != 198 {
+			t.Errorf("expect sum 198 of the first 2 books, got:%v, err:%v", sum, err)
+		}
+		avg, err = db.Tb(t_book).Select().Where("tag = ?", 99).Avg("tag")
+		if err != nil || avg != 99 {
+			t.Errorf("expect avg 99, got:%v, err:%v", avg, err)
+		}
+		var name string
+		err = db.Tb(t_book).Select().Max("name", &name)
+		if err != nil || name != "Tencent" {
+			t.Errorf("expect max Tencent, got:%s, err:%v", name, err)
+		}
+		var minTag sql.NullInt64
+		err = db.Tb(t_book).Select().Where("tag > ?", 100).Min("tag", &minTag)
+		if err != nil || minTag.Valid {
+			t.Errorf("expect null min, got:%v, err:%v", minTag, err)
+		}
+		exists, err := db.Tb(t_book).Select().Where("name = ?", "Golang").Exists()
+		if err != nil || !exists {
+			t.Errorf("expect exists, err:%v", err)
+		}
+		exists, err = db.Tb(t_book).Select().Where("name = ?", "Java").Exists()
+		if err != nil || exists {
+			t.Errorf("expect not exists, err:%v", err)
+		}
+	})
+}
+
+func TestSelect_OrderBy(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		db := NewDB(sb)
+		book_set := []map[string]interface{}{
+			{"name":"Python", "tag":99},
+			{"name":"Golang"},
+			{"name":"Tencent", "tag":88},
+			{"name":"Rust", "tag":99},
+		}
+		for _, book := range book_set {
+			_, err := db.Tb(t_book).InsertMap(book).Done()
+			if err != nil {
+				t.Errorf("fail to insert books, err:%v", err)
+			}
+		}
+		var books []struct{
+			Name string `db:"name"`
+		}
+		err := db.Tb(t_book).Select("name").
+			OrderBy(Desc("tag").NullsLast(), "id ASC").All(&books)
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		var names []string
+		for _, b := range books {
+			names = append(names, b.Name)
+		}
+		expect := []string{"Python", "Rust", "Tencent", "Golang"}
+		if !reflect.DeepEqual(names, expect) {
+			t.Errorf("expect %v, got:%v", expect, names)
+		}
+
+		// OrderAsc and OrderDesc are chained too
+		books = nil
+		err = db.Tb(t_book).Select("name").Where("tag IS NOT NULL").
+			OrderAsc("tag").OrderDesc("name").All(&books)
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		if len(books) != 3 || books[0].Name != "Tencent" || books[1].Name != "Rust" {
+			t.Errorf("expect Tencent, Rust, Python, got:%+v", books)
+		}
+	})
+}
+
+func TestSelect_Page(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		db := NewDB(sb)
+		for _, name := range []string{"A", "B", "C", "D", "E"} {
+			_, err := db.Tb(t_book).InsertMap(map[string]interface{}{"name": name, "tag": 1}).Done()
+			if err != nil {
+				t.Errorf("fail to insert books, err:%v", err)
+			}
+		}
+		var books []tBook
+		err := db.Tb(t_book).Select().OrderAsc("name").Limit(2).Offset(1).All(&books)
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		if len(books) != 2 || books[0].Name != "B" || books[1].Name != "C" {
+			t.Errorf("expect B and C, got:%+v", books)
+		}
+
+		books = nil
+		total, err := db.Tb(t_book).Select().Where("tag = ?", 1).OrderAsc("name").Page(3, 2, &books)
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		if total != 5 {
+			t.Errorf("expect total 5, got:%d", total)
+		}
+		if len(books) != 1 || books[0].Name != "E" {
+			t.Errorf("expect E on the last page, got:%+v", books)
+		}
+
+		books = nil
+		total, err = db.Tb(t_book).Select().OrderAsc("name").Page(4, 2, &books)
+		if err != nil || total != 5 || len(books) != 0 {
+			t.Errorf("expect empty page, got:%+v, err:%v", books, err)
+		}
+	})
+}
+
+func TestSelect_Cursor(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		db := NewDB(sb)
+		book_set := []map[string]interface{}{
+			{"name":"A", "tag":1}, {"name":"B", "tag":2}, {"name":"C", "tag":2},
+			{"name":"D", "tag":3}, {"name":"E", "tag":3},
+		}
+		for _, book := range book_set {
+			_, err := db.Tb(t_book).InsertMap(book).Done()
+			if err != nil {
+				t.Errorf("fail to insert books, err:%v", err)
+			}
+		}
+		names := func(books []tBook) (ns []string) {
+			for _, b := range books {
+				ns = append(ns, b.Name)
+			}
+			return ns
+		}
+		var pages [][]string
+		var cursor *Cursor
+		for more := true; more && len(pages) < 5; {
+			var books []tBook
+			s := db.Tb(t_book).Select().OrderBy("tag DESC", "name ASC").Limit(2)
+			err := s.After(cursor).All(&books)
+			if err != nil {
+				t.Errorf("got err:%v", err)
+			}
+			pages = append(pages, names(books))
+			var next *Cursor
+			next, more, err = s.NextCursor()
+			if err != nil {
+				t.Errorf("got err:%v", err)
+			}
+			// cursors go through clients as strings
+			cursor, err = DecodeCursor(next.Encode())
+			if err != nil {
+				t.Errorf("got err:%v", err)
+			}
+		}
+		// the short page is the end
+		expect := [][]string{{"D", "E"}, {"B", "C"}, {"A"}}
+		if !reflect.DeepEqual(pages, expect) {
+			t.Errorf("expect %v, got:%v", expect, pages)
+		}
+
+		// the page after the last row is empty and the end as well
+		var books []tBook
+		s := db.Tb(t_book).Select().OrderBy("tag DESC", "name ASC").Limit(2)
+		if err := s.After(&Cursor{Values:[]interface{}{1, "A"}}).All(&books); err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		if next, more, err := s.NextCursor(); len(books) != 0 || next != nil || more || err != nil {
+			t.Errorf("expect the end, got:%v, %+v, %v, err:%v", names(books), next, more, err)
+		}
+
+		// order columns must be scanned to the model
+		s = db.Tb(t_book).Select().OrderBy("deleted", "name").Limit(2)
+		if err := s.All(&books); err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		if _, _, err := s.NextCursor(); err == nil {
+			t.Errorf("expect err of unmapped order column")
+		}
+
+		// back to the page before `A`
+		books = nil
+		s = db.Tb(t_book).Select().OrderBy("tag DESC", "name ASC").Limit(2)
+		err := s.Before(&Cursor{Values:[]interface{}{1, "A"}}).All(&books)
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		if !reflect.DeepEqual(names(books), []string{"B", "C"}) {
+			t.Errorf("expect B and C, got:%v", names(books))
+		}
+		prev, more, err := s.PrevCursor()
+		if err != nil || !more || prev == nil || !reflect.DeepEqual(prev.Values, []interface{}{2, "B"}) {
+			t.Errorf("expect cursor of B, got:%+v, %v, err:%v", prev, more, err)
+		}
+		if _, more, _ := s.NextCursor(); !more {
+			t.Errorf("expect more rows after the page")
+		}
+
+		// streamed rows can't be reversed
+		it := db.Tb(t_book).Select().OrderBy("tag DESC", "name ASC").Before(prev).Iter()
+		if it.Next() || it.Err() == nil {
+			t.Errorf("expect err of Iter before a cursor")
+		}
+	})
+}
+
+func TestSelect_Iter(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		db := NewDB(sb)
+		for _, name := range []string{"A", "B", "C"} {
+			_, err := db.Tb(t_book).InsertMap(map[string]interface{}{"name": name, "tag": 1}).Done()
+			if err != nil {
+				t.Errorf("fail to insert books, err:%v", err)
+			}
+		}
+		// all columns to a model without some of them
+		it := db.Tb(t_book).Select().OrderAsc("name").Iter()
+		var names []string
+		for it.Next() {
+			var book tBook
+			if !it.Get(&book) {
+				break
+			}
+			names = append(names, book.Name)
+		}
+		if err := it.Err(); err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		if err := it.Close(); err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		if !reflect.DeepEqual(names, []string{"A", "B", "C"}) {
+			t.Errorf("expect A, B, C, got:%v", names)
+		}
+
+		// rows to maps
+		it = db.Tb(t_book).Select("name", "tag").Where("name = ?", "B").Iter()
+		defer it.Close()
+		var count int
+		for it.Next() {
+			row := map[string]interface{}{}
+			if !it.Get(row) {
+				break
+			}
+			count++
+			if _, ok := row["tag"]; !ok {
+				t.Errorf("expect tag column, got:%v", row)
+			}
+		}
+		if it.Err() != nil || count != 1 {
+			t.Errorf("expect 1 row, got:%d, err:%v", count, it.Err())
+		}
+
+		// errors come back from Err
+		it = db.Tb("no_such_table").Select().Iter()
+		if it.Next() || it.Err() == nil {
+			t.Error("expect err on missing table")
+		}
+	})
+}
+
+func TestDB_WithContext(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		db := NewDB(sb)
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err := db.WithContext(ctx).Tb(t_book).InsertMap(map[string]interface{}{"name": "Python"}).Done()
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		cancel()
+
+		// a canceled context aborts queries
+		var books []tBook
+		err = db.WithContext(ctx).Tb(t_book).Select().All(&books)
+		if err != context.Canceled {
+			t.Errorf("expect canceled err, got:%v", err)
+		}
+		_, err = db.Tb(t_book).WithContext(ctx).Delete().Where("name = ?", "Python").Done()
+		if err != context.Canceled {
+			t.Errorf("expect canceled err, got:%v", err)
+		}
+		// the db itself is not bound to the context
+		cnt, err := db.Tb(t_book).Select().Count()
+		if err != nil || cnt != 1 {
+			t.Errorf("expect 1 row, got:%d, err:%v", cnt, err)
+		}
+	})
+}
+
+func TestDB_WithLogger(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		var buf bytes.Buffer
+		logger := logrus.New()
+		logger.Out = &buf
+		logger.Formatter = &logrus.JSONFormatter{}
+		logger.Level = logrus.DebugLevel
+		db := NewDB(sb, WithLogger(NewLogrusLogger(logrus.NewEntry(logger))))
+		_, err := db.Tb(t_book).InsertMap(map[string]interface{}{"name": "Python"}).Done()
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("expect a json entry, got:%q, err:%v", buf.String(), err)
+		}
+		if entry["msg"] != "[Exec]" || entry["level"] != "debug" {
+			t.Errorf("expect debug [Exec], got:%v", entry)
+		}
+		for _, field := range []string{"query", "args", "duration"} {
+			if _, ok := entry[field]; !ok {
+				t.Errorf("expect field %s, got:%v", field, entry)
+			}
+		}
+
+		// failed queries are logged as errors
+		buf.Reset()
+		var books []tBook
+		err = db.Tb("no_such_table").Select().All(&books)
+		if err == nil {
+			t.Errorf("expect err")
+		}
+		entry = nil
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("expect a json entry, got:%q, err:%v", buf.String(), err)
+		}
+		if entry["msg"] != "[Select]" || entry["level"] != "error" || entry["error"] == nil {
+			t.Errorf("expect error [Select], got:%v", entry)
+		}
+	})
+}
+
+type slowRecorder struct {
+	nopLogger
+	queries []string
+	rows    []int64
+	callers []string
+}
+
+func (r *slowRecorder) Warn(spec string, query string, rows int64, caller string, elapsed time.Duration) {
+	r.queries = append(r.queries, query)
+	r.rows = append(r.rows, rows)
+	r.callers = append(r.callers, caller)
+}
+
+func TestDB_WithSlowQuery(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		rec := &slowRecorder{}
+		db := NewDB(sb, WithLogger(rec), WithSlowQuery(time.Nanosecond))
+		_, err := db.Tb(t_book).InsertMap(map[string]interface{}{"name": "Python"}).Done()
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		var books []tBook
+		err = db.Tb(t_book).Select("name").Where("name = ?", "Python").All(&books)
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		if len(rec.queries) != 2 {
+			t.Fatalf("expect 2 slow queries, got:%v", rec.queries)
+		}
+		if !strings.Contains(rec.queries[1], "name = 'Python'") {
+			t.Errorf("expect interpolated sql, got:%s", rec.queries[1])
+		}
+		if rec.rows[0] != 1 || rec.rows[1] != 1 {
+			t.Errorf("expect 1 row affected and returned, got:%v", rec.rows)
+		}
+		for _, caller := range rec.callers {
+			if !strings.Contains(caller, "table_test.go:") {
+				t.Errorf("expect the test as caller, got:%s", caller)
+			}
+		}
+
+		// fast queries are not logged
+		rec.queries = nil
+		db = NewDB(sb, WithLogger(rec), WithSlowQuery(time.Hour))
+		err = db.Tb(t_book).Select("name").All(&books)
+		if err != nil || len(rec.queries) != 0 {
+			t.Errorf("expect no slow queries, got:%v, err:%v", rec.queries, err)
+		}
+	})
+}
+
+type argsRecorder struct {
+	nopLogger
+	args [][]interface{}
+}
+
+func (r *argsRecorder) Debug(spec string, query string, args []interface{}, elapsed time.Duration) {
+	r.args = append(r.args, args)
+}
+
+func TestDB_WithRedaction(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		rec := &argsRecorder{}
+		db := NewDB(sb, WithLogger(rec), WithRedaction(&Redaction{Columns: []string{"name"}}))
+		_, err := db.Tb(t_book).InsertMap(map[string]interface{}{"name": "Python"}).Done()
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		_, err = db.Tb(t_book).Delete().Where("name = ?", "Python").Done()
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		expect := [][]interface{}{{redacted}, {redacted}}
+		if !reflect.DeepEqual(rec.args, expect) {
+			t.Errorf("expect %v, got:%v", expect, rec.args)
+		}
+	})
+}
+
+func TestDB_WithRedaction_secret(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		rec := &argsRecorder{}
+		db := NewDB(sb, WithLogger(rec), WithRedaction(&Redaction{Columns: []string{t_author + ".name"}}))
+		// the policy works before any model is used, only on the table of the column
+		_, err := db.Tb(t_author).InsertMap(map[string]interface{}{"name": "Tom"}).Done()
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		_, err = db.Tb(t_book).InsertMap(map[string]interface{}{"name": "Python"}).Done()
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+
+		// secret columns of the model are hidden in the queries built with it only
+		type Book struct {
+			M
+			Name string `db:"name,secret"`
+		}
+		_, err = db.Tb(t_book).Insert(&Book{Name: "Golang"}).Done()
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		_, err = db.Tb(t_book).InsertMap(map[string]interface{}{"name": "Rust"}).Done()
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		other := NewDB(sb, WithLogger(rec))
+		_, err = other.Tb(t_book).Delete().Where("name = ?", "Rust").Done()
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		expect := [][]interface{}{{redacted}, {"Python"}, {redacted}, {"Rust"}, {"Rust"}}
+		if !reflect.DeepEqual(rec.args, expect) {
+			t.Errorf("expect %v, got:%v", expect, rec.args)
+		}
+	})
+}
+
+type hookKey struct{}
+
+type recordHook struct {
+	events []QueryEvent
+	errs   []error
+	ctxOK  []bool
+}
+
+func (h *recordHook) Before(ctx context.Context, e QueryEvent) context.Context {
+	return context.WithValue(ctx, hookKey{}, e.SQL)
+}
+
+func (h *recordHook) After(ctx context.Context, e QueryEvent, err error) {
+	h.events = append(h.events, e)
+	h.errs = append(h.errs, err)
+	h.ctxOK = append(h.ctxOK, ctx.Value(hookKey{}) == e.SQL)
+}
+
+func TestDB_WithHook(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		hook := &recordHook{}
+		db := NewDB(sb, WithHook(hook))
+		for _, name := range []string{"Python", "Golang"} {
+			_, err := db.Tb(t_book).InsertMap(map[string]interface{}{"name": name}).Done()
+			if err != nil {
+				t.Errorf("got err:%v", err)
+			}
+		}
+		var books []tBook
+		err := db.Tb(t_book).Select("name").Where("name IN ?", []string{"Python", "Golang"}).All(&books)
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		err = db.Tb("no_such_table").Select().All(&books)
+		if err == nil {
+			t.Errorf("expect err")
+		}
+		if len(hook.events) != 4 {
+			t.Fatalf("expect 4 events, got:%+v", hook.events)
+		}
+		insert, sel, failed := hook.events[0], hook.events[2], hook.events[3]
+		if insert.Op != "INSERT" || insert.Table != t_book || insert.ArgCount != 1 || insert.RowsAffected != 1 {
+			t.Errorf("unexpected insert event:%+v", insert)
+		}
+		if sel.Op != "SELECT" || sel.Table != t_book || sel.ArgCount != 2 || sel.RowsAffected != 2 {
+			t.Errorf("unexpected select event:%+v", sel)
+		}
+		if failed.Table != "no_such_table" || failed.RowsAffected != -1 || hook.errs[3] == nil {
+			t.Errorf("unexpected failed event:%+v, err:%v", failed, hook.errs[3])
+		}
+		for i, ok := range hook.ctxOK {
+			if !ok {
+				t.Errorf("expect the context of Before in After of event %d", i)
+			}
+		}
+	})
+}
+
+var t_audit = "test_audit"
+
+var audit_scheme = Scheme{
+	create: append([]string{`
+	CREATE TABLE test_audit(
+	  id INTEGER AUTO_INCREMENT PRIMARY KEY,
+	  table_name VARCHAR(64) NOT NULL,
+	  pk VARCHAR(64) NOT NULL,
+	  op VARCHAR(8) NOT NULL,
+	  old_data TEXT NULL,
+	  new_data TEXT NULL,
+	  actor VARCHAR(64) NOT NULL,
+	  created_at DATETIME NOT NULL);`}, test_scheme.create...),
+	drop: "drop table test_audit, test_book, test_author;",
+
+	sqliteCreate: append([]string{`
+	CREATE TABLE test_audit(
+	  id INTEGER PRIMARY KEY AUTOINCREMENT,
+	  table_name VARCHAR(64) NOT NULL,
+	  pk VARCHAR(64) NOT NULL,
+	  op VARCHAR(8) NOT NULL,
+	  old_data TEXT NULL,
+	  new_data TEXT NULL,
+	  actor VARCHAR(64) NOT NULL,
+	  created_at DATETIME NOT NULL);`}, test_scheme.sqliteCreate...),
+	sqliteDrop: "drop table test_audit;" + test_scheme.sqliteDrop,
+}
+
+type tAudit struct {
+	TableName string `db:"table_name"`
+	PK string `db:"pk"`
+	Op string `db:"op"`
+	OldData sql.NullString `db:"old_data"`
+	NewData sql.NullString `db:"new_data"`
+	Actor string `db:"actor"`
+}
+
+type failSink struct{}
+
+func (failSink) Write(ctx context.Context, db *DB, r *AuditRecord) error {
+	return errors.New("sink is down")
+}
+
+func TestDB_WithAudit(t *testing.T) {
+	RunWithScheme(audit_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		db := NewDB(sb, WithAudit(NewTableSink(t_audit)))
+		ctx := WithActor(context.Background(), "tom")
+		book := tTrackedBook{Name: "Python", Tag: 1}
+		other := tTrackedBook{Name: "Golang", Tag: 1}
+		// audited changes out of a transaction run in one
+		if _, err := db.Tb(t_book).WithContext(ctx).Insert(&book).Done(); err != nil {
+			t.Fatalf("got err:%v", err)
+		}
+		err := db.InTx(ctx, nil, func(tx *Tx) error {
+			if _, err := tx.Tb(t_book).Insert(&other).Done(); err != nil {
+				return err
+			}
+			Watch(&book)
+			book.Tag = 2
+			if _, err := tx.Tb(t_book).Update(&book).Where("id = ?", book.Id).Done(); err != nil {
+				return err
+			}
+			// a copy is audited on itself
+			for _, b := range []tTrackedBook{book} {
+				b.Tag = 3
+				if cnt, err := tx.Tb(t_book).Update(&b).Where("id = ?", b.Id).Done(); err != nil || cnt != 1 {
+					return fmt.Errorf("expect 1 row of the copy, got:%d, err:%v", cnt, err)
+				}
+			}
+			// no rows updated, nothing audited
+			book.Tag = 4
+			if cnt, err := tx.Tb(t_book).Update(&book).Where("tag = ?", 99).Done(); err != nil || cnt != 0 {
+				return fmt.Errorf("expect no rows, got:%d, err:%v", cnt, err)
+			}
+			// the old values of a model not watched are loaded
+			if _, err := tx.Tb(t_book).Update(&tTrackedBook{Id: other.Id, Name: "Rust", Tag: 1}).
+				Where("id = ?", other.Id).Done(); err != nil {
+				return err
+			}
+			// every row deleted by the where is audited
+			cnt, err := tx.Tb(t_book).Delete(&book).Where("tag > ?", 0).Done()
+			if err != nil || cnt != 2 {
+				return fmt.Errorf("expect 2 rows deleted, got:%d, err:%v", cnt, err)
+			}
+			// models without tracking are not audited
+			_, err = tx.Tb(t_book).Insert(&tBook{Name: "Java"}).Done()
+			return err
+		})
+		if err != nil {
+			t.Fatalf("got err:%v", err)
+		}
+
+		var records []tAudit
+		err = db.Tb(t_audit).Select().OrderAsc("id").All(&records)
+		if err != nil {
+			t.Fatalf("got err:%v", err)
+		}
+		pk, otherPK := fmt.Sprint(book.Id), fmt.Sprint(other.Id)
+		data := func(s string) sql.NullString {
+			return sql.NullString{String: s, Valid: true}
+		}
+		expect := []tAudit{
+			{t_book, pk, AuditInsert, sql.NullString{}, data(`{"id":1,"name":"Python","tag":1}`), "tom"},
+			{t_book, otherPK, AuditInsert, sql.NullString{}, data(`{"id":2,"name":"Golang","tag":1}`), "tom"},
+			{t_book, pk, AuditUpdate, data(`{"tag":1}`), data(`{"tag":2}`), "tom"},
+			{t_book, pk, AuditUpdate, data(`{"tag":2}`), data(`{"tag":3}`), "tom"},
+			{t_book, otherPK, AuditUpdate, data(`{"id":2,"name":"Golang","tag":1}`),
+				data(`{"id":2,"name":"Rust","tag":1}`), "tom"},
+			{t_book, pk, AuditDelete, data(`{"id":1,"name":"Python","tag":3}`), sql.NullString{}, "tom"},
+			{t_book, otherPK, AuditDelete, data(`{"id":2,"name":"Rust","tag":1}`), sql.NullString{}, "tom"},
+		}
+		if !reflect.DeepEqual(records, expect) {
+			t.Errorf("expect %+v, got:%+v", expect, records)
+		}
+		if cnt, _ := db.Tb(t_book).Select().Count(); cnt != 1 {
+			t.Errorf("expect 1 book left, got:%d", cnt)
+		}
+
+		// a failed write rolls back the change
+		failed := NewDB(sb, WithAudit(failSink{}))
+		if _, err = failed.Tb(t_book).Insert(&tTrackedBook{Name: "Lost"}).Done(); err == nil {
+			t.Errorf("expect err of the sink")
+		}
+		if cnt, _ := db.Tb(t_book).Select().Where("name = ?", "Lost").Count(); cnt != 0 {
+			t.Errorf("expect the insert rolled back, got:%d", cnt)
+		}
+	})
+}
+
+func TestDB_InTx(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		db := NewDB(sb)
+		ctx := context.Background()
+		err := db.InTx(ctx, nil, func(tx *Tx) error {
+			book := &tBook{Name:"Python", Tag:99}
+			if _, err := tx.Tb(t_book).Insert(book).Done(); err != nil {
+				return err
+			}
+			book.Tag = 100
+			_, err := tx.Tb(t_book).Update(book).Done()
+			return err
+		})
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		var b tBook
+		err = db.Tb(t_book).Select().Where("name = ?", "Python").Get(&b)
+		if err != nil || b.Tag != 100 {
+			t.Errorf("expect committed book with tag 100, got:%+v, err:%v", b, err)
+		}
+
+		// rolled back on error
+		errAbort := errors.New("abort")
+		err = db.InTx(ctx, nil, func(tx *Tx) error {
+			if _, err := tx.Tb(t_book).Delete().Where("name = ?", "Python").Done(); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if err != errAbort {
+			t.Errorf("expect abort err, got:%v", err)
+		}
+
+		// rolled back on panic
+		func() {
+			defer func() {
+				if re := recover(); re == nil {
+					t.Error("expect panic")
+				}
+			}()
+			db.InTx(ctx, nil, func(tx *Tx) error {
+				tx.Tb(t_book).Delete().Where("name = ?", "Python").Done()
+				panic("boom")
+			})
+		}()
+
+		cnt, err := db.Tb(t_book).Select().Count()
+		if err != nil || cnt != 1 {
+			t.Errorf("expect book kept, got:%d, err:%v", cnt, err)
+		}
+	})
+}
+
+func TestDB_InTxRetry(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		db := NewDB(sb)
+		var errRetryable error = errors.New("database is locked")
+		if db.Dialect() == MySQL {
+			errRetryable = &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+		}
+		attempts := 0
+		err := db.InTx(context.Background(), nil, func(tx *Tx) error {
+			attempts++
+			_, err := tx.Tb(t_book).InsertMap(map[string]interface{}{"name": "Python"}).Done()
+			if err != nil {
+				return err
+			}
+			if attempts < 3 {
+				return errRetryable
+			}
+			return nil
+		}, WithRetry(3, time.Millisecond))
+		if err != nil || attempts != 3 {
+			t.Errorf("expect success on attempt 3, got:%d, err:%v", attempts, err)
+		}
+		cnt, err := db.Tb(t_book).Select().Count()
+		if err != nil || cnt != 1 {
+			t.Errorf("expect 1 book, got:%d, err:%v", cnt, err)
+		}
+
+		// gives up after max attempts, and never retries other errors
+		attempts = 0
+		err = db.InTx(context.Background(), nil, func(tx *Tx) error {
+			attempts++
+			return errRetryable
+		}, WithRetry(2, time.Millisecond))
+		if err != errRetryable || attempts != 2 {
+			t.Errorf("expect 2 attempts, got:%d, err:%v", attempts, err)
+		}
+		attempts = 0
+		errOther := errors.New("other")
+		err = db.InTx(context.Background(), nil, func(tx *Tx) error {
+			attempts++
+			return errOther
+		}, WithRetry(3, time.Millisecond))
+		if err != errOther || attempts != 1 {
+			t.Errorf("expect 1 attempt, got:%d, err:%v", attempts, err)
+		}
+	})
+}
+
+func TestTx_InTx(t *testing.T) {
+	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
+		db := NewDB(sb)
+		errAbort := errors.New("abort")
+		insert := func(name string) func(tx *Tx) error {
+			return func(tx *Tx) error {
+				_, err := tx.Tb(t_book).InsertMap(map[string]interface{}{"name": name}).Done()
+				return err
+			}
+		}
+		err := db.InTx(context.Background(), nil, func(tx *Tx) error {
+			if err := insert("Python")(tx); err != nil {
+				return err
+			}
+			// the failed nested transaction is rolled back alone
+			err := tx.InTx(func(tx *Tx) error {
+				if tx.Depth() != 1 {
+					t.Errorf("expect depth 1, got:%d", tx.Depth())
+				}
+				if err := insert("Golang")(tx); err != nil {
+					return err
+				}
+				// nested deeper and committed
+				if err := tx.InTx(insert("Rust")); err != nil {
+					return err
+				}
+				return errAbort
+			})
+			if err != errAbort {
+				t.Errorf("expect abort err, got:%v", err)
+			}
+			return tx.InTx(insert("Tencent"))
+		})
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		var books []tBook
+		err = db.Tb(t_book).Select("name").OrderAsc("name").All(&books)
+		if err != nil {
+			t.Errorf("got err:%v", err)
+		}
+		if len(books) != 2 || books[0].Name != "Python" || books[1].Name != "Tencent" {
+			t.Errorf("expect Python and Tencent, got:%+v", books)
+		}
+	})
+}
+
 func TestSelect_All_join(t *testing.T) {
 	RunWithScheme(test_scheme, t, func(sb *sqlx.DB, t *testing.T){
 		db := NewDB(sb)
@@ -0,0 +1,217 @@
+package om
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Tx is a transaction with the same `Tb(...)` builders as `DB`
+type Tx struct {
+	// db runs queries of the builders on the transaction
+	db *DB
+	tx *sqlx.Tx
+	// depth of nested `InTx`, it names savepoints
+	depth int
+}
+
+// Begin starts a transaction, the context is used until the transaction ends
+func (m *DB) Begin(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	if m.sqlxDB == nil {
+		return nil, errors.New("can't begin a transaction in a transaction")
+	}
+	if ctx == nil {
+		ctx = m.context()
+	}
+	w := m.dbx
+	begin := time.Now()
+	tx, err := m.sqlxDB.BeginTxx(ctx, opts)
+	if err != nil {
+		w.logger.Error("[Begin]", err, "BEGIN", nil, time.Since(begin))
+		return nil, err
+	}
+	w.logger.Debug("[Begin]", "BEGIN", nil, time.Since(begin))
+	// queries of the tx are logged as the db's
+	txConn := *w
+	txConn.DB = tx
+	txDB := &DB{
+		dbx:&txConn,
+		dialect:m.dialect,
+		ctx:ctx,
+		audit:m.audit,
+	}
+	return &Tx{db:txDB, tx:tx}, nil
+}
+
+// TxOption configures `DB.InTx`
+type TxOption func(c *txConfig)
+
+type txConfig struct {
+	// max attempts to run the transaction, 1 means no retry
+	maxAttempts int
+	// base and max delay of the backoff between attempts
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// WithRetry retries the transaction on errors the dialect classifies as retryable,
+// such as deadlocks or serialization failures, the delay before the nth retry
+// is a random duration in [0, backoff * 2^(n-1)), at most 1000 times of backoff
+func WithRetry(maxAttempts int, backoff time.Duration) TxOption {
+	return func(c *txConfig) {
+		c.maxAttempts = maxAttempts
+		c.backoff = backoff
+		c.maxBackoff = backoff * 1000
+	}
+}
+
+// InTx runs fn in a transaction, the transaction is committed if fn returns nil,
+// or rolled back if fn returns an error or panics,
+// fn may run more than once if `WithRetry` is given
+//
+// Example:
+//	err := db.InTx(ctx, nil, func(tx *om.Tx) error {
+//		if _, err := tx.Tb("book").Insert(&book).Done(); err != nil {
+//			return err
+//		}
+//		_, err := tx.Tb("author").UpdateMap(cols).Where("id = ?", id).Done()
+//		return err
+//	}, om.WithRetry(3, 10*time.Millisecond))
+func (m *DB) InTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *Tx) error,
+	options ...TxOption) (err error) {
+	c := &txConfig{maxAttempts: 1}
+	for _, option := range options {
+		option(c)
+	}
+	if ctx == nil {
+		ctx = m.context()
+	}
+	for attempt := 1; ; attempt++ {
+		err = m.inTx(ctx, opts, fn)
+		if err == nil || attempt >= c.maxAttempts || !m.dialect.IsRetryable(err) {
+			return err
+		}
+		delay := c.backoff << uint(attempt-1)
+		if delay <= 0 || delay > c.maxBackoff {
+			delay = c.maxBackoff
+		}
+		if delay > 0 {
+			delay = time.Duration(rand.Int63n(int64(delay)))
+		}
+		m.dbx.logger.Warn("[Retry]",
+			fmt.Sprintf("retry transaction in %v, attempt %d/%d, failed by:%v", delay, attempt+1, c.maxAttempts, err),
+			-1, callerOf(), delay)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (m *DB) inTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *Tx) error) (err error) {
+	tx, err := m.Begin(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if re := recover(); re != nil {
+			tx.Rollback()
+			panic(re)
+		}
+	}()
+	err = fn(tx)
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w, and fail to rollback:%v", err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// InTx runs fn in a nested transaction by a savepoint,
+// it rolls back to the savepoint if fn returns an error or panics,
+// so the outer transaction can recover and go on
+//
+// Example:
+//	err := db.InTx(ctx, nil, func(tx *om.Tx) error {
+//		// failure of the optional step doesn't abort the outer transaction
+//		if err := tx.InTx(optionalStep); err != nil {
+//			log.Println(err)
+//		}
+//		return requiredStep(tx)
+//	})
+func (tx *Tx) InTx(fn func(tx *Tx) error) (err error) {
+	tx.depth++
+	defer func() {
+		tx.depth--
+	}()
+	d := tx.db.dialect
+	name := fmt.Sprintf("om_sp_%d", tx.depth)
+	if _, err = tx.exec(d.Savepoint(name)); err != nil {
+		return err
+	}
+	defer func() {
+		if re := recover(); re != nil {
+			tx.exec(d.RollbackToSavepoint(name))
+			tx.exec(d.ReleaseSavepoint(name))
+			panic(re)
+		}
+	}()
+	err = fn(tx)
+	if err != nil {
+		if _, rbErr := tx.exec(d.RollbackToSavepoint(name)); rbErr != nil {
+			return fmt.Errorf("%w, and fail to rollback to savepoint:%v", err, rbErr)
+		}
+		tx.exec(d.ReleaseSavepoint(name))
+		return err
+	}
+	_, err = tx.exec(d.ReleaseSavepoint(name))
+	return err
+}
+
+// Depth returns the depth of nested `InTx`, 0 for the outermost transaction
+func (tx *Tx) Depth() int {
+	return tx.depth
+}
+
+func (tx *Tx) exec(query string) (sql.Result, error) {
+	return tx.db.dbx.Exec(tx.db.context(), query)
+}
+
+// Tb starts builders on the table in the transaction
+func (tx *Tx) Tb(table string, alias ...string) *Tables {
+	return NewTables(tx.db, table, alias...)
+}
+
+// Commit commits the transaction
+func (tx *Tx) Commit() error {
+	w := tx.db.dbx
+	begin := time.Now()
+	err := tx.tx.Commit()
+	if err != nil {
+		w.logger.Error("[Commit]", err, "COMMIT", nil, time.Since(begin))
+		return err
+	}
+	w.logger.Debug("[Commit]", "COMMIT", nil, time.Since(begin))
+	return nil
+}
+
+// Rollback aborts the transaction
+func (tx *Tx) Rollback() error {
+	w := tx.db.dbx
+	begin := time.Now()
+	err := tx.tx.Rollback()
+	if err != nil {
+		w.logger.Error("[Rollback]", err, "ROLLBACK", nil, time.Since(begin))
+		return err
+	}
+	w.logger.Debug("[Rollback]", "ROLLBACK", nil, time.Since(begin))
+	return nil
+}